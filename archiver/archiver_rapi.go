@@ -0,0 +1,139 @@
+package archiver
+
+import (
+	"context"
+	"time"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/filter"
+	"github.com/rubiojr/rapi/internal/fs"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// BackupOptions collect the settings used by Backup to create a new snapshot.
+type BackupOptions struct {
+	// Excludes is a list of patterns (see the filter package for the syntax)
+	// matching files and directories that are not saved.
+	Excludes []string
+	Tags     restic.TagList
+	Hostname string
+	// Parent is the snapshot used to detect unchanged files. When it is
+	// null, all files are read again.
+	Parent restic.ID
+	// Time is the timestamp recorded in the snapshot, time.Now() is used
+	// when it is zero.
+	Time time.Time
+
+	Progress BackupProgress
+}
+
+// BackupProgress holds the callbacks Backup uses to report progress. All of
+// them are optional and may be called concurrently.
+type BackupProgress struct {
+	// StartFile is called when a file is about to be read.
+	StartFile func(filename string)
+	// CompleteBlob is called when a chunk of a file has been saved.
+	CompleteBlob func(filename string, bytes uint64)
+	// CompleteItem is called when a file or directory has been saved.
+	CompleteItem func(item string, previous, current *restic.Node, s ItemStats, d time.Duration)
+	// ReportTotal is called by the scanner running in the background with the
+	// cumulated stats of the targets, item is empty once the scan is done.
+	ReportTotal func(item string, s ScanStats)
+	// Error is called when reading an item fails. When it returns nil, the
+	// item is skipped and the backup continues. When nil, the backup is
+	// aborted on the first error.
+	Error ErrorFunc
+	// ScannerError is called when the scanner fails to read an item.
+	ScannerError ErrorFunc
+}
+
+// Backup saves the targets to repo and writes a new snapshot, returning the
+// snapshot and its ID. The repository index is loaded before saving.
+func Backup(ctx context.Context, repo restic.Repository, targets []string, opts BackupOptions) (*restic.Snapshot, restic.ID, error) {
+	if len(targets) == 0 {
+		return nil, restic.ID{}, errors.New("nothing to backup, please specify target files/dirs")
+	}
+
+	selectByName := excludeByPattern(opts.Excludes)
+
+	err := repo.LoadIndex(ctx)
+	if err != nil {
+		return nil, restic.ID{}, err
+	}
+
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+
+	targetFS := &fs.Local{}
+
+	scanDone := make(chan struct{})
+	scanCtx, cancelScan := context.WithCancel(ctx)
+	if opts.Progress.ReportTotal != nil {
+		sc := NewScanner(targetFS)
+		sc.SelectByName = selectByName
+		sc.Result = opts.Progress.ReportTotal
+		if opts.Progress.ScannerError != nil {
+			sc.Error = opts.Progress.ScannerError
+		}
+
+		// the scanner only reports progress, errors must not abort the backup
+		go func() {
+			defer close(scanDone)
+			err := sc.Scan(scanCtx, targets)
+			if err != nil {
+				debug.Log("scanner returned error: %v", err)
+			}
+		}()
+	} else {
+		close(scanDone)
+	}
+
+	arch := New(repo, targetFS, Options{})
+	arch.SelectByName = selectByName
+	if opts.Progress.Error != nil {
+		arch.Error = opts.Progress.Error
+	}
+	if opts.Progress.CompleteItem != nil {
+		arch.CompleteItem = opts.Progress.CompleteItem
+	}
+	if opts.Progress.StartFile != nil {
+		arch.StartFile = opts.Progress.StartFile
+	}
+	if opts.Progress.CompleteBlob != nil {
+		arch.CompleteBlob = opts.Progress.CompleteBlob
+	}
+
+	sn, id, err := arch.Snapshot(ctx, targets, SnapshotOptions{
+		Tags:           opts.Tags,
+		Hostname:       opts.Hostname,
+		Excludes:       opts.Excludes,
+		Time:           opts.Time,
+		ParentSnapshot: opts.Parent,
+	})
+
+	cancelScan()
+	<-scanDone
+	if err != nil {
+		return nil, restic.ID{}, err
+	}
+
+	return sn, id, nil
+}
+
+// excludeByPattern returns a SelectByNameFunc which rejects all items
+// matching one of the patterns.
+func excludeByPattern(patterns []string) SelectByNameFunc {
+	parsed := filter.ParsePatterns(patterns)
+	return func(item string) bool {
+		matched, err := filter.List(parsed, item)
+		if err != nil {
+			debug.Log("error for exclude pattern: %v", err)
+		}
+		if matched {
+			debug.Log("path %q excluded by an exclude pattern", item)
+		}
+		return !matched
+	}
+}
@@ -0,0 +1,102 @@
+package archiver
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	restictest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestBackup(t *testing.T) {
+	tempdir, repo, cleanup := prepareTempdirRepoSrc(t, TestDir{
+		"target": TestDir{
+			"foo":     TestFile{Content: "foo"},
+			"bar.tmp": TestFile{Content: "bar"},
+			"subdir": TestDir{
+				"baz":     TestFile{Content: "baz"},
+				"qux.tmp": TestFile{Content: "qux"},
+			},
+		},
+	})
+	defer cleanup()
+
+	back := restictest.Chdir(t, tempdir)
+	defer back()
+
+	var (
+		m       sync.Mutex
+		started []string
+	)
+	opts := BackupOptions{
+		Excludes: []string{"*.tmp"},
+		Tags:     restic.TagList{"foo"},
+		Hostname: "example",
+		Progress: BackupProgress{
+			StartFile: func(filename string) {
+				m.Lock()
+				started = append(started, filename)
+				m.Unlock()
+			},
+		},
+	}
+
+	sn, id, err := Backup(context.TODO(), repo, []string{"target"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sn.Hostname != "example" {
+		t.Errorf("wrong hostname, want %q, got %q", "example", sn.Hostname)
+	}
+	if !sn.HasTags([]string{"foo"}) {
+		t.Errorf("snapshot is missing tag foo: %v", sn.Tags)
+	}
+	if len(sn.Excludes) != 1 || sn.Excludes[0] != "*.tmp" {
+		t.Errorf("wrong excludes recorded in snapshot: %v", sn.Excludes)
+	}
+	if len(started) != 2 {
+		t.Errorf("wrong number of files started, want 2, got %v", started)
+	}
+
+	TestEnsureSnapshot(t, repo, id, TestDir{
+		"target": TestDir{
+			"foo": TestFile{Content: "foo"},
+			"subdir": TestDir{
+				"baz": TestFile{Content: "baz"},
+			},
+		},
+	})
+
+	// a second backup using the first snapshot as parent must not read any
+	// files again
+	started = nil
+	opts.Parent = id
+	opts.Time = time.Now()
+	sn2, _, err := Backup(context.TODO(), repo, []string{"target"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sn2.Parent == nil || !sn2.Parent.Equal(id) {
+		t.Errorf("wrong parent, want %v, got %v", id, sn2.Parent)
+	}
+	if !sn2.Tree.Equal(*sn.Tree) {
+		t.Errorf("tree changed for identical backup: %v != %v", sn2.Tree, sn.Tree)
+	}
+	if len(started) != 0 {
+		t.Errorf("unchanged files were read again: %v", started)
+	}
+}
+
+func TestBackupNoTargets(t *testing.T) {
+	_, repo, cleanup := prepareTempdirRepoSrc(t, TestDir{})
+	defer cleanup()
+
+	_, _, err := Backup(context.TODO(), repo, nil, BackupOptions{})
+	if err == nil {
+		t.Fatal("expected error for empty target list")
+	}
+}
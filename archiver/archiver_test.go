@@ -17,7 +17,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/rubiojr/rapi/backend/mem"
-	"github.com/rubiojr/rapi/internal/checker"
+	"github.com/rubiojr/rapi/checker"
 	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/internal/fs"
 	"github.com/rubiojr/rapi/repository"
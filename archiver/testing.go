@@ -234,6 +234,8 @@ func TestEnsureFileContent(ctx context.Context, t testing.TB, repo restic.Reposi
 			return
 		}
 
+		// LoadBlob may return a new buffer for compressed blobs
+		copy(content[pos:pos+len(part)], part)
 		pos += len(part)
 	}
 
@@ -0,0 +1,129 @@
+package restserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rubiojr/rapi/backend"
+	"github.com/rubiojr/rapi/backend/local"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// Backends provides the backends of the repositories served. Repositories
+// are identified by their slash separated path below the server root, the
+// repository at the root has the empty path.
+type Backends interface {
+	// Open returns the backend of the repository at path repo, or
+	// ErrRepoNotFound if there is no such repository.
+	Open(ctx context.Context, repo string) (restic.Backend, error)
+
+	// Create prepares a new repository at path repo and returns its
+	// backend. Creating a repository which already exists is not an error.
+	Create(ctx context.Context, repo string) (restic.Backend, error)
+}
+
+// LocalBackends serves all the repositories below a local directory.
+type LocalBackends struct {
+	path string
+
+	m        sync.Mutex
+	backends map[string]*local.Local
+}
+
+// NewLocalBackends returns Backends which serves the repositories below the
+// directory path.
+func NewLocalBackends(path string) *LocalBackends {
+	return &LocalBackends{
+		path:     path,
+		backends: make(map[string]*local.Local),
+	}
+}
+
+// Open returns the backend of the repository at path repo, which must
+// contain a config file unless it was created by Create.
+func (l *LocalBackends) Open(ctx context.Context, repo string) (restic.Backend, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if be, ok := l.backends[repo]; ok {
+		return be, nil
+	}
+
+	// only repositories which were initialized or created by a request are
+	// served, everything else would create files at arbitrary paths
+	cfg := l.config(repo)
+	_, err := os.Stat(filepath.Join(cfg.Path, backend.Paths.Config))
+	if os.IsNotExist(err) {
+		return nil, ErrRepoNotFound
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	be, err := local.Open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	l.backends[repo] = be
+	return be, nil
+}
+
+// Create creates the directories of the repository at path repo.
+func (l *LocalBackends) Create(ctx context.Context, repo string) (restic.Backend, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	cfg := l.config(repo)
+	be, err := local.Open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := be.Test(ctx, restic.Handle{Type: restic.ConfigFile})
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		be, err = local.Create(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l.backends[repo] = be
+	return be, nil
+}
+
+func (l *LocalBackends) config(repo string) local.Config {
+	return local.Config{
+		Path:   filepath.Join(l.path, filepath.FromSlash(repo)),
+		Layout: "default",
+	}
+}
+
+// singleBackend serves a single backend as the repository at the root.
+type singleBackend struct {
+	be restic.Backend
+}
+
+// NewSingleBackend returns Backends which serves be as the repository at the
+// root of the server.
+func NewSingleBackend(be restic.Backend) Backends {
+	return singleBackend{be: be}
+}
+
+func (s singleBackend) Open(ctx context.Context, repo string) (restic.Backend, error) {
+	if repo != "" {
+		return nil, ErrRepoNotFound
+	}
+	return s.be, nil
+}
+
+func (s singleBackend) Create(ctx context.Context, repo string) (restic.Backend, error) {
+	return s.Open(ctx, repo)
+}
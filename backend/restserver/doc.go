@@ -0,0 +1,6 @@
+// Package restserver implements the server side of restic's REST protocol,
+// versions 1 and 2, on top of any restic.Backend. It is compatible with the
+// rest backend client and with the rest-server project, including its
+// append-only mode, private repositories, htpasswd authentication and
+// Prometheus metrics.
+package restserver
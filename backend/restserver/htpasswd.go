@@ -0,0 +1,79 @@
+package restserver
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rubiojr/rapi/internal/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Htpasswd holds the users and password hashes of an htpasswd file. Only
+// bcrypt and SHA1 hashes are supported, as created by `htpasswd -B` and
+// `htpasswd -s`.
+type Htpasswd struct {
+	users map[string]string
+}
+
+// LoadHtpasswd reads the htpasswd file at path.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	return ParseHtpasswd(f)
+}
+
+// ParseHtpasswd parses the htpasswd file contents read from rd.
+func ParseHtpasswd(rd io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: make(map[string]string)}
+
+	sc := bufio.NewScanner(rd)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		i := strings.Index(s, ":")
+		if i <= 0 {
+			return nil, errors.Errorf("htpasswd line %d: invalid entry", line)
+		}
+
+		user, hash := s[:i], s[i+1:]
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, errors.Errorf("htpasswd line %d: unsupported hash for user %q, use bcrypt or SHA1", line, user)
+		}
+
+		h.users[user] = hash
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+
+	return h, nil
+}
+
+// Validate returns true if user exists and password matches its hash.
+func (h *Htpasswd) Validate(user, password string) bool {
+	hash, ok := h.users[user]
+	if !ok {
+		return false
+	}
+
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) == 1
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
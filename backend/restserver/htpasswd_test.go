@@ -0,0 +1,43 @@
+package restserver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rubiojr/rapi/backend/restserver"
+	rtest "github.com/rubiojr/rapi/internal/test"
+)
+
+func TestHtpasswd(t *testing.T) {
+	// created with `htpasswd -nbB alice secret` and `htpasswd -nbs bob secret`
+	htpasswd, err := restserver.ParseHtpasswd(strings.NewReader(`
+# comment
+alice:$2y$05$saa1T.sValsEFrzreqtbVu3WXkFzdQfvQHvC6IK6tfdQRNTnxCJi2
+bob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+`))
+	rtest.OK(t, err)
+
+	for _, test := range []struct {
+		user, password string
+		valid          bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "wrong", false},
+		{"bob", "secret", true},
+		{"bob", "wrong", false},
+		{"carol", "secret", false},
+	} {
+		rtest.Equals(t, test.valid, htpasswd.Validate(test.user, test.password))
+	}
+}
+
+func TestHtpasswdUnsupported(t *testing.T) {
+	for _, s := range []string{
+		"alice:$apr1$O5ZxQ1Cp$9x4mCjrGBWrw0cP0UB5VB/\n",
+		"alice:plaintext\n",
+		"invalid\n",
+	} {
+		_, err := restserver.ParseHtpasswd(strings.NewReader(s))
+		rtest.Assert(t, err != nil, "parsing %q did not fail", s)
+	}
+}
@@ -0,0 +1,101 @@
+package restserver
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// the metric names are the same as the ones exported by rest-server
+var metricNames = []struct {
+	name, help string
+}{
+	{"rest_server_blob_read_total", "Total number of blobs read"},
+	{"rest_server_blob_read_bytes_total", "Total number of bytes read from blobs"},
+	{"rest_server_blob_write_total", "Total number of blobs written"},
+	{"rest_server_blob_write_bytes_total", "Total number of bytes written to blobs"},
+	{"rest_server_blob_delete_total", "Total number of blobs deleted"},
+	{"rest_server_blob_delete_bytes_total", "Total number of bytes of blobs deleted"},
+}
+
+const (
+	metricRead = iota
+	metricReadBytes
+	metricWrite
+	metricWriteBytes
+	metricDelete
+	metricDeleteBytes
+
+	numMetrics
+)
+
+type metricLabels struct {
+	user, repo, tpe string
+}
+
+// metrics keeps the counters exported in the Prometheus text format.
+type metrics struct {
+	m        sync.Mutex
+	counters map[metricLabels]*[numMetrics]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{counters: make(map[metricLabels]*[numMetrics]uint64)}
+}
+
+// add increments the counter and its bytes counter for the labels.
+func (m *metrics) add(metric int, l metricLabels, bytes int64) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	c, ok := m.counters[l]
+	if !ok {
+		c = new([numMetrics]uint64)
+		m.counters[l] = c
+	}
+
+	c[metric]++
+	if bytes > 0 {
+		c[metric+1] += uint64(bytes)
+	}
+}
+
+// write writes all the counters to w.
+func (m *metrics) write(w io.Writer) error {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	labels := make([]metricLabels, 0, len(m.counters))
+	for l := range m.counters {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.user != b.user {
+			return a.user < b.user
+		}
+		if a.repo != b.repo {
+			return a.repo < b.repo
+		}
+		return a.tpe < b.tpe
+	})
+
+	for i, metric := range metricNames {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", metric.name, metric.help, metric.name)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range labels {
+			_, err = fmt.Fprintf(w, "%s{repo=%s,type=%s,user=%s} %d\n", metric.name,
+				strconv.Quote(l.repo), strconv.Quote(l.tpe), strconv.Quote(l.user), m.counters[l][i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,532 @@
+package restserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rubiojr/rapi/backend/rest"
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// ErrRepoNotFound is returned by Backends when a repository does not exist.
+var ErrRepoNotFound = errors.New("repository not found")
+
+// Config configures a Server.
+type Config struct {
+	// AppendOnly prevents the deletion of files, except locks.
+	AppendOnly bool
+
+	// PrivateRepos restricts the users to the repositories below a
+	// directory named like them. Requires Htpasswd.
+	PrivateRepos bool
+
+	// Htpasswd authenticates the users, no authentication is done when
+	// it is nil.
+	Htpasswd *Htpasswd
+
+	// Metrics exports Prometheus metrics at /metrics.
+	Metrics bool
+
+	// Logf is called for errors of the backends. It may be nil.
+	Logf func(format string, args ...interface{})
+}
+
+// Server is an http.Handler which serves repositories using restic's REST
+// protocol.
+type Server struct {
+	cfg      Config
+	backends Backends
+	metrics  *metrics
+}
+
+// New returns a Server which serves the repositories of backends.
+func New(backends Backends, cfg Config) (*Server, error) {
+	if cfg.PrivateRepos && cfg.Htpasswd == nil {
+		return nil, errors.New("private repositories require authentication")
+	}
+
+	return &Server{
+		cfg:      cfg,
+		backends: backends,
+		metrics:  newMetrics(),
+	}, nil
+}
+
+// the directory names of the file types, as used by the rest backend
+var fileTypes = map[string]restic.FileType{
+	"data":      restic.PackFile,
+	"keys":      restic.KeyFile,
+	"locks":     restic.LockFile,
+	"snapshots": restic.SnapshotFile,
+	"index":     restic.IndexFile,
+}
+
+// request is a parsed request path.
+type request struct {
+	user string
+	repo string
+
+	// list is set for requests to list the files of a type, h.Type is set
+	// then. Otherwise h is the file requested, or empty for requests to the
+	// repository itself.
+	list bool
+	h    restic.Handle
+
+	// dir is the directory name of the file type, used for the metrics
+	dir string
+}
+
+// parsePath splits the URL path p into the repository and the file
+// requested.
+func parsePath(p string) (request, error) {
+	var req request
+
+	trailingSlash := strings.HasSuffix(p, "/")
+	p = strings.Trim(p, "/")
+
+	var segments []string
+	if p != "" {
+		segments = strings.Split(p, "/")
+	}
+
+	n := len(segments)
+	switch {
+	case n > 0 && segments[n-1] == "config" && !trailingSlash:
+		req.h = restic.Handle{Type: restic.ConfigFile}
+		req.dir = "config"
+		segments = segments[:n-1]
+	case n > 0 && fileTypes[segments[n-1]] != "":
+		req.list = true
+		req.h = restic.Handle{Type: fileTypes[segments[n-1]]}
+		req.dir = segments[n-1]
+		segments = segments[:n-1]
+	case n > 1 && fileTypes[segments[n-2]] != "" && !trailingSlash:
+		req.h = restic.Handle{Type: fileTypes[segments[n-2]], Name: segments[n-1]}
+		req.dir = segments[n-2]
+		if !validName(req.h.Name) {
+			return req, errors.Errorf("invalid file name %q", req.h.Name)
+		}
+		segments = segments[:n-2]
+	}
+
+	for _, s := range segments {
+		if s == "" || strings.HasPrefix(s, ".") {
+			return req, errors.Errorf("invalid repository path %q", p)
+		}
+	}
+	req.repo = strings.Join(segments, "/")
+
+	return req, nil
+}
+
+// validName returns true if name is the hex string of an ID.
+func validName(name string) bool {
+	if len(name) != 2*len(restic.ID{}) {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debug.Log("%v %v", r.Method, r.URL.Path)
+
+	var user string
+	if s.cfg.Htpasswd != nil {
+		var password string
+		var ok bool
+		user, password, ok = r.BasicAuth()
+		if !ok || !s.cfg.Htpasswd.Validate(user, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="restic"`)
+			httpError(w, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if s.cfg.Metrics && r.URL.Path == "/metrics" {
+		if r.Method != http.MethodGet {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := s.metrics.write(w); err != nil {
+			s.logf("writing metrics failed: %v", err)
+		}
+		return
+	}
+
+	req, err := parsePath(r.URL.Path)
+	if err != nil {
+		debug.Log("invalid request path %v: %v", r.URL.Path, err)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+	req.user = user
+
+	if s.cfg.PrivateRepos && req.repo != user && !strings.HasPrefix(req.repo, user+"/") {
+		httpError(w, http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	if req.h.Type == "" {
+		// only creating the repository is supported
+		if r.Method != http.MethodPost || r.URL.Query().Get("create") != "true" {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+
+		if _, err := s.backends.Create(ctx, req.repo); err != nil {
+			s.serverError(w, err)
+		}
+		return
+	}
+
+	be, err := s.backends.Open(ctx, req.repo)
+	if errors.Cause(err) == ErrRepoNotFound {
+		httpError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+
+	if req.list {
+		if r.Method != http.MethodGet {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		s.list(ctx, w, r, be, req)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		s.stat(ctx, w, be, req)
+	case http.MethodGet:
+		s.load(ctx, w, r, be, req)
+	case http.MethodPost:
+		s.save(ctx, w, r, be, req)
+	case http.MethodDelete:
+		s.remove(ctx, w, be, req)
+	default:
+		httpError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) list(ctx context.Context, w http.ResponseWriter, r *http.Request, be restic.Backend, req request) {
+	type fileInfo struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}
+
+	v2 := r.Header.Get("Accept") == rest.ContentTypeV2
+
+	names := []string{}
+	files := []fileInfo{}
+	err := be.List(ctx, req.h.Type, func(fi restic.FileInfo) error {
+		if v2 {
+			files = append(files, fileInfo{Name: fi.Name, Size: fi.Size})
+		} else {
+			names = append(names, fi.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+
+	var buf []byte
+	if v2 {
+		w.Header().Set("Content-Type", rest.ContentTypeV2)
+		buf, err = json.Marshal(files)
+	} else {
+		w.Header().Set("Content-Type", rest.ContentTypeV1)
+		buf, err = json.Marshal(names)
+	}
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+
+	_, _ = w.Write(buf)
+}
+
+func (s *Server) stat(ctx context.Context, w http.ResponseWriter, be restic.Backend, req request) {
+	fi, err := be.Stat(ctx, req.h)
+	if err != nil {
+		s.backendError(w, be, err)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
+}
+
+// parseRange parses the value of a Range header for a file with the given
+// size and returns the offset and length requested.
+func parseRange(s string, size int64) (offset, length int64, err error) {
+	if !strings.HasPrefix(s, "bytes=") || strings.Contains(s, ",") {
+		return 0, 0, errors.Errorf("unsupported range %q", s)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(s, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.Errorf("invalid range %q", s)
+	}
+
+	if parts[0] == "" {
+		// the last bytes of the file
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, errors.Errorf("invalid range %q", s)
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, nil
+	}
+
+	offset, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, 0, errors.Errorf("invalid range %q", s)
+	}
+
+	end := size - 1
+	if parts[1] != "" {
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || end < offset {
+			return 0, 0, errors.Errorf("invalid range %q", s)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return offset, end - offset + 1, nil
+}
+
+func (s *Server) load(ctx context.Context, w http.ResponseWriter, r *http.Request, be restic.Backend, req request) {
+	fi, err := be.Stat(ctx, req.h)
+	if err != nil {
+		s.backendError(w, be, err)
+		return
+	}
+
+	status := http.StatusOK
+	offset, length := int64(0), fi.Size
+	if rng := r.Header.Get("Range"); rng != "" {
+		offset, length, err = parseRange(rng, fi.Size)
+		if err != nil {
+			debug.Log("%v", err)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size))
+			httpError(w, http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, fi.Size))
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+
+	// an empty file can't be loaded with a length of 0, which means the
+	// whole file
+	if length == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	headerWritten := false
+	err = be.Load(ctx, req.h, int(length), offset, func(rd io.Reader) error {
+		w.WriteHeader(status)
+		headerWritten = true
+		_, err := io.Copy(w, rd)
+		return err
+	})
+	if err != nil {
+		if !headerWritten {
+			s.backendError(w, be, err)
+			return
+		}
+		// the status was sent already, the client notices the short body
+		s.logf("loading %v failed: %v", req.h, err)
+		return
+	}
+
+	s.metrics.add(metricRead, req.labels(), length)
+}
+
+// maxBufferedUpload limits the size of the uploads which are kept in memory
+// for backends asking for the hash of the data before saving it.
+const maxBufferedUpload = 128 << 20
+
+// uploadReader streams the body of an upload to a backend. Reading fails at
+// the end of the body if the data does not match the ID of the file.
+type uploadReader struct {
+	rd     io.Reader
+	length int64
+	id     string
+	hash   hash.Hash
+	read   int64
+
+	// err is the error reading the body or verifying its hash
+	err error
+}
+
+func newUploadReader(rd io.Reader, length int64, h restic.Handle) *uploadReader {
+	u := &uploadReader{rd: rd, length: length, hash: sha256.New()}
+	// the names of all files except the config are the hash of their
+	// contents, make sure the upload was not corrupted
+	if h.Type != restic.ConfigFile {
+		u.id = h.Name
+	}
+	return u
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.rd.Read(p)
+	_, _ = u.hash.Write(p[:n])
+	u.read += int64(n)
+
+	switch {
+	case err == io.EOF && u.id != "" && hex.EncodeToString(u.hash.Sum(nil)) != u.id:
+		u.err = errors.Errorf("hash of the data does not match %v", u.id)
+	case err != nil && err != io.EOF:
+		u.err = err
+	}
+	if u.err != nil {
+		return n, u.err
+	}
+	return n, err
+}
+
+// Rewind fails once data was read, the body of the request can only be read
+// once.
+func (u *uploadReader) Rewind() error {
+	if u.read > 0 {
+		return errors.New("the upload cannot be rewound")
+	}
+	return nil
+}
+
+func (u *uploadReader) Length() int64 {
+	return u.length
+}
+
+func (u *uploadReader) Hash() []byte {
+	return nil
+}
+
+func (s *Server) save(ctx context.Context, w http.ResponseWriter, r *http.Request, be restic.Backend, req request) {
+	if r.ContentLength < 0 {
+		httpError(w, http.StatusLengthRequired)
+		return
+	}
+
+	// files are never overwritten
+	exists, err := be.Test(ctx, req.h)
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+	if exists {
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	upload := newUploadReader(r.Body, r.ContentLength, req.h)
+
+	var rd restic.RewindReader = upload
+	if hasher := be.Hasher(); hasher != nil {
+		// the backend needs the hash of the data up front
+		upload.rd = http.MaxBytesReader(w, r.Body, maxBufferedUpload)
+		buf, err := ioutil.ReadAll(upload)
+		if err != nil {
+			debug.Log("reading the body of %v failed: %v", req.h, err)
+			httpError(w, http.StatusBadRequest)
+			return
+		}
+		rd = restic.NewByteReader(buf, hasher)
+	}
+
+	err = be.Save(ctx, req.h, rd)
+	if upload.err != nil {
+		debug.Log("reading the body of %v failed: %v", req.h, upload.err)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.serverError(w, err)
+		return
+	}
+
+	s.metrics.add(metricWrite, req.labels(), r.ContentLength)
+}
+
+func (s *Server) remove(ctx context.Context, w http.ResponseWriter, be restic.Backend, req request) {
+	if s.cfg.AppendOnly && req.h.Type != restic.LockFile {
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	fi, err := be.Stat(ctx, req.h)
+	if err != nil {
+		s.backendError(w, be, err)
+		return
+	}
+
+	if err = be.Remove(ctx, req.h); err != nil {
+		s.backendError(w, be, err)
+		return
+	}
+
+	s.metrics.add(metricDelete, req.labels(), fi.Size)
+}
+
+func (req request) labels() metricLabels {
+	return metricLabels{user: req.user, repo: req.repo, tpe: req.dir}
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	debug.Log(format, args...)
+	if s.cfg.Logf != nil {
+		s.cfg.Logf(format+"\n", args...)
+	}
+}
+
+// backendError responds with 404 if err is caused by a missing file, and
+// with 500 otherwise.
+func (s *Server) backendError(w http.ResponseWriter, be restic.Backend, err error) {
+	if be.IsNotExist(err) {
+		httpError(w, http.StatusNotFound)
+		return
+	}
+	s.serverError(w, err)
+}
+
+func (s *Server) serverError(w http.ResponseWriter, err error) {
+	s.logf("%v", err)
+	httpError(w, http.StatusInternalServerError)
+}
+
+func httpError(w http.ResponseWriter, status int) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+// make sure Server implements http.Handler
+var _ http.Handler = &Server{}
@@ -0,0 +1,288 @@
+package restserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rubiojr/rapi/backend"
+	"github.com/rubiojr/rapi/backend/mem"
+	"github.com/rubiojr/rapi/backend/rest"
+	"github.com/rubiojr/rapi/backend/restserver"
+	"github.com/rubiojr/rapi/backend/test"
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/restic"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestSuite(t testing.TB, u *url.URL) *test.Suite {
+	tr, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		t.Fatalf("cannot create transport for tests: %v", err)
+	}
+
+	return &test.Suite{
+		MinimalData: true,
+
+		NewConfig: func() (interface{}, error) {
+			cfg := rest.NewConfig()
+			cfg.URL = u
+			return cfg, nil
+		},
+
+		Create: func(config interface{}) (restic.Backend, error) {
+			return rest.Create(context.TODO(), config.(rest.Config), tr)
+		},
+
+		Open: func(config interface{}) (restic.Backend, error) {
+			return rest.Open(config.(rest.Config), tr)
+		},
+
+		Cleanup: func(config interface{}) error {
+			return nil
+		},
+	}
+}
+
+func newServer(t testing.TB, backends restserver.Backends, cfg restserver.Config) (*httptest.Server, func()) {
+	srv, err := restserver.New(backends, cfg)
+	rtest.OK(t, err)
+
+	ts := httptest.NewServer(srv)
+	return ts, ts.Close
+}
+
+func TestBackendLocal(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ts, cleanup := newServer(t, restserver.NewLocalBackends(dir), restserver.Config{})
+	defer cleanup()
+
+	u, err := url.Parse(ts.URL + "/restic-test/")
+	rtest.OK(t, err)
+
+	newTestSuite(t, u).RunTests(t)
+}
+
+func TestBackendMem(t *testing.T) {
+	ts, cleanup := newServer(t, restserver.NewSingleBackend(mem.New()), restserver.Config{})
+	defer cleanup()
+
+	u, err := url.Parse(ts.URL + "/")
+	rtest.OK(t, err)
+
+	newTestSuite(t, u).RunTests(t)
+}
+
+func request(t testing.TB, method, u string, body string, header http.Header) *http.Response {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	rtest.OK(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	rtest.OK(t, err)
+	return res
+}
+
+func checkStatus(t testing.TB, res *http.Response, status int) {
+	t.Helper()
+	_, _ = ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if res.StatusCode != status {
+		t.Fatalf("%v %v: want status %d, got %d", res.Request.Method, res.Request.URL, status, res.StatusCode)
+	}
+}
+
+func TestAppendOnly(t *testing.T) {
+	ts, cleanup := newServer(t, restserver.NewSingleBackend(mem.New()), restserver.Config{AppendOnly: true})
+	defer cleanup()
+
+	data := "foobar"
+	id := restic.Hash([]byte(data)).String()
+
+	for _, dir := range []string{"data", "keys", "locks", "snapshots", "index"} {
+		u := ts.URL + "/" + dir + "/" + id
+		checkStatus(t, request(t, http.MethodPost, u, data, nil), http.StatusOK)
+		// files can't be overwritten
+		checkStatus(t, request(t, http.MethodPost, u, data, nil), http.StatusForbidden)
+
+		if dir == "locks" {
+			checkStatus(t, request(t, http.MethodDelete, u, "", nil), http.StatusOK)
+		} else {
+			checkStatus(t, request(t, http.MethodDelete, u, "", nil), http.StatusForbidden)
+			checkStatus(t, request(t, http.MethodHead, u, "", nil), http.StatusOK)
+		}
+	}
+
+	checkStatus(t, request(t, http.MethodDelete, ts.URL+"/config", "", nil), http.StatusForbidden)
+}
+
+func TestInvalidUpload(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	local := restserver.NewLocalBackends(dir)
+	_, err := local.Create(context.TODO(), "")
+	rtest.OK(t, err)
+
+	// the local backend streams the uploads, the memory backend buffers them
+	for _, backends := range []restserver.Backends{local, restserver.NewSingleBackend(mem.New())} {
+		ts, cleanup := newServer(t, backends, restserver.Config{})
+		defer cleanup()
+
+		id := restic.Hash([]byte("foobar")).String()
+		checkStatus(t, request(t, http.MethodPost, ts.URL+"/data/"+id, "other data", nil), http.StatusBadRequest)
+		checkStatus(t, request(t, http.MethodHead, ts.URL+"/data/"+id, "", nil), http.StatusNotFound)
+		checkStatus(t, request(t, http.MethodPost, ts.URL+"/data/"+id, "foobar", nil), http.StatusOK)
+
+		checkStatus(t, request(t, http.MethodPost, ts.URL+"/data/foobar", "foobar", nil), http.StatusBadRequest)
+		checkStatus(t, request(t, http.MethodGet, ts.URL+"/../data/"+id, "", nil), http.StatusBadRequest)
+		checkStatus(t, request(t, http.MethodGet, ts.URL+"/other/data/", "", nil), http.StatusNotFound)
+
+		// the size of uploads must be known
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/keys/"+id, ioutil.NopCloser(strings.NewReader("foobar")))
+		rtest.OK(t, err)
+		res, err := http.DefaultClient.Do(req)
+		rtest.OK(t, err)
+		checkStatus(t, res, http.StatusLengthRequired)
+	}
+}
+
+func TestListV1(t *testing.T) {
+	ts, cleanup := newServer(t, restserver.NewSingleBackend(mem.New()), restserver.Config{})
+	defer cleanup()
+
+	id := restic.Hash([]byte("foobar")).String()
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/snapshots/"+id, "foobar", nil), http.StatusOK)
+
+	res := request(t, http.MethodGet, ts.URL+"/snapshots/", "", nil)
+	rtest.Equals(t, rest.ContentTypeV1, res.Header.Get("Content-Type"))
+
+	var names []string
+	rtest.OK(t, json.NewDecoder(res.Body).Decode(&names))
+	rtest.OK(t, res.Body.Close())
+	rtest.Equals(t, []string{id}, names)
+}
+
+func TestLocalBackendsNotFound(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ts, cleanup := newServer(t, restserver.NewLocalBackends(dir), restserver.Config{})
+	defer cleanup()
+
+	id := restic.Hash([]byte("foobar")).String()
+	checkStatus(t, request(t, http.MethodGet, ts.URL+"/repo/keys/", "", nil), http.StatusNotFound)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/repo/data/"+id, "foobar", nil), http.StatusNotFound)
+	_, err := os.Stat(filepath.Join(dir, "repo"))
+	rtest.Assert(t, os.IsNotExist(err), "uploading to a missing repository created it: %v", err)
+
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/repo/?create=true", "", nil), http.StatusOK)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/repo/config", "config", nil), http.StatusOK)
+
+	// initialized repositories are served after a restart
+	ts, cleanup = newServer(t, restserver.NewLocalBackends(dir), restserver.Config{})
+	defer cleanup()
+
+	checkStatus(t, request(t, http.MethodGet, ts.URL+"/repo/keys/", "", nil), http.StatusOK)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/repo/data/"+id, "foobar", nil), http.StatusOK)
+}
+
+func TestPrivateRepos(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	rtest.OK(t, err)
+
+	htpasswd, err := restserver.ParseHtpasswd(strings.NewReader("alice:" + string(hash) + "\n"))
+	rtest.OK(t, err)
+
+	_, err = restserver.New(restserver.NewSingleBackend(mem.New()), restserver.Config{PrivateRepos: true})
+	rtest.Assert(t, err != nil, "private repositories without authentication did not fail")
+
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ts, cleanup := newServer(t, restserver.NewLocalBackends(dir), restserver.Config{
+		PrivateRepos: true,
+		Htpasswd:     htpasswd,
+	})
+	defer cleanup()
+
+	auth := func(user, password string) http.Header {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		req.SetBasicAuth(user, password)
+		return req.Header
+	}
+
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/alice/?create=true", "", nil), http.StatusUnauthorized)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/alice/?create=true", "", auth("alice", "wrong")), http.StatusUnauthorized)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/alice/?create=true", "", auth("alice", "secret")), http.StatusOK)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/alice/sub/?create=true", "", auth("alice", "secret")), http.StatusOK)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/bob/?create=true", "", auth("alice", "secret")), http.StatusUnauthorized)
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/?create=true", "", auth("alice", "secret")), http.StatusUnauthorized)
+	checkStatus(t, request(t, http.MethodGet, ts.URL+"/alice/sub/keys/", "", auth("alice", "secret")), http.StatusOK)
+}
+
+func TestMetrics(t *testing.T) {
+	ts, cleanup := newServer(t, restserver.NewSingleBackend(mem.New()), restserver.Config{Metrics: true})
+	defer cleanup()
+
+	data := "foobar"
+	id := restic.Hash([]byte(data)).String()
+	checkStatus(t, request(t, http.MethodPost, ts.URL+"/data/"+id, data, nil), http.StatusOK)
+	checkStatus(t, request(t, http.MethodGet, ts.URL+"/data/"+id, "", nil), http.StatusOK)
+	checkStatus(t, request(t, http.MethodGet, ts.URL+"/data/"+id, "", nil), http.StatusOK)
+
+	res := request(t, http.MethodGet, ts.URL+"/metrics", "", nil)
+	buf, err := ioutil.ReadAll(res.Body)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+
+	for _, line := range []string{
+		`rest_server_blob_write_total{repo="",type="data",user=""} 1`,
+		`rest_server_blob_write_bytes_total{repo="",type="data",user=""} 6`,
+		`rest_server_blob_read_total{repo="",type="data",user=""} 2`,
+		`rest_server_blob_read_bytes_total{repo="",type="data",user=""} 12`,
+	} {
+		rtest.Assert(t, strings.Contains(string(buf), line+"\n"), "metric %q not found in:\n%s", line, buf)
+	}
+}
+
+func TestRange(t *testing.T) {
+	ts, cleanup := newServer(t, restserver.NewSingleBackend(mem.New()), restserver.Config{})
+	defer cleanup()
+
+	data := "0123456789"
+	u := ts.URL + "/data/" + restic.Hash([]byte(data)).String()
+	checkStatus(t, request(t, http.MethodPost, u, data, nil), http.StatusOK)
+
+	for _, test := range []struct {
+		rng, body, contentRange string
+	}{
+		{"bytes=2-4", "234", "bytes 2-4/10"},
+		{"bytes=7-", "789", "bytes 7-9/10"},
+		{"bytes=8-20", "89", "bytes 8-9/10"},
+		{"bytes=-2", "89", "bytes 8-9/10"},
+	} {
+		res := request(t, http.MethodGet, u, "", http.Header{"Range": {test.rng}})
+		buf, err := ioutil.ReadAll(res.Body)
+		rtest.OK(t, err)
+		rtest.OK(t, res.Body.Close())
+
+		rtest.Equals(t, http.StatusPartialContent, res.StatusCode)
+		rtest.Equals(t, test.body, string(buf))
+		rtest.Equals(t, test.contentRange, res.Header.Get("Content-Range"))
+	}
+
+	res := request(t, http.MethodGet, u, "", http.Header{"Range": {"bytes=10-"}})
+	checkStatus(t, res, http.StatusRequestedRangeNotSatisfiable)
+}
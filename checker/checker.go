@@ -7,6 +7,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/rubiojr/rapi/crypto"
 	"github.com/rubiojr/rapi/internal/debug"
 	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/pack"
@@ -501,6 +502,20 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, size int6
 			continue
 		}
 
+		if blob.IsCompressed() {
+			plaintext, err = crypto.Decompress(nil, plaintext)
+			if err != nil {
+				debug.Log("  error decompressing blob %v: %v", blob.ID, err)
+				errs = append(errs, errors.Errorf("blob %v: %v", i, err))
+				continue
+			}
+			if uint(len(plaintext)) != blob.UncompressedLength {
+				errs = append(errs, errors.Errorf("blob %v: uncompressed length does not match, want %v, got %v",
+					i, blob.UncompressedLength, len(plaintext)))
+				continue
+			}
+		}
+
 		hash := restic.Hash(plaintext)
 		if !hash.Equal(blob.ID) {
 			debug.Log("  Blob ID does not match, want %v, got %v", blob.ID, hash)
@@ -511,7 +526,7 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, size int6
 		// Check if blob is contained in index and position is correct
 		idxHas := false
 		for _, pb := range idx.Lookup(blob.BlobHandle) {
-			if pb.PackID == id && pb.Offset == blob.Offset && pb.Length == blob.Length {
+			if pb.PackID == id && pb.Blob == blob {
 				idxHas = true
 				break
 			}
@@ -528,7 +543,7 @@ func checkPack(ctx context.Context, r restic.Repository, id restic.ID, size int6
 	}
 
 	if len(errs) > 0 {
-		return errors.Errorf("pack %v contains %v errors: %v", id.Str(), len(errs), errs)
+		return errors.Errorf("contains %v errors: %v", len(errs), errs)
 	}
 
 	return nil
@@ -574,7 +589,7 @@ func (c *Checker) ReadPacks(ctx context.Context, packs map[restic.ID]int64, p *p
 				select {
 				case <-ctx.Done():
 					return nil
-				case errChan <- err:
+				case errChan <- PackError{ID: ps.id, Err: err}:
 				}
 			}
 		})
@@ -0,0 +1,130 @@
+package checker
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// PackSubset returns the packs ReadPacks should read for the given subset,
+// which is either "n/m" to read the n-th of m groups of packs, or a
+// percentage like "2.5%" to read a random selection of packs.
+//
+// Groups are stable as they are based on the pack ID, so reading all groups
+// from 1/m to m/m in consecutive runs checks every pack in the repository.
+func (c *Checker) PackSubset(subset string) (map[restic.ID]int64, error) {
+	if strings.HasSuffix(subset, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(subset, "%"), 64)
+		if err != nil || percentage <= 0 || percentage > 100 {
+			return nil, errors.Errorf("invalid percentage %q, must be between 0%% and 100%%", subset)
+		}
+		return selectRandomPacksByPercentage(c.packs, percentage), nil
+	}
+
+	parts := strings.Split(subset, "/")
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid subset %q, use n/m or a percentage", subset)
+	}
+
+	bucket, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid subset %q: %v", subset, err)
+	}
+	totalBuckets, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("invalid subset %q: %v", subset, err)
+	}
+	if totalBuckets < 2 || bucket < 1 || bucket > totalBuckets {
+		return nil, errors.Errorf("invalid subset %q, n must be between 1 and m, m at least 2", subset)
+	}
+
+	return selectPacksByBucket(c.packs, uint(bucket), uint(totalBuckets)), nil
+}
+
+// selectPacksByBucket selects the packs whose ID falls into the given bucket.
+// The first four bytes of the ID are used, so that every bucket gets packs
+// even when there are more than 256 buckets.
+func selectPacksByBucket(allPacks map[restic.ID]int64, bucket, totalBuckets uint) map[restic.ID]int64 {
+	packs := make(map[restic.ID]int64)
+	for id, size := range allPacks {
+		if uint(binary.BigEndian.Uint32(id[:4]))%totalBuckets == bucket-1 {
+			packs[id] = size
+		}
+	}
+	return packs
+}
+
+// selectRandomPacksByPercentage selects the given percentage of packs,
+// rounded up so that at least one pack is read.
+func selectRandomPacksByPercentage(allPacks map[restic.ID]int64, percentage float64) map[restic.ID]int64 {
+	count := int(float64(len(allPacks))*percentage/100 + 0.99999)
+	ids := make(restic.IDs, 0, len(allPacks))
+	for id := range allPacks {
+		ids = append(ids, id)
+	}
+
+	packs := make(map[restic.ID]int64)
+	for _, i := range rand.Perm(len(ids))[:count] {
+		packs[ids[i]] = allPacks[ids[i]]
+	}
+	return packs
+}
+
+// ErrorReport is the JSON representation of an error or hint returned by
+// the Checker.
+type ErrorReport struct {
+	// Type is one of "pack", "tree", "blob", "duplicate_packs",
+	// "old_index_format" or "error" for errors without more details.
+	Type     string         `json:"type"`
+	Message  string         `json:"message"`
+	PackID   *restic.ID     `json:"pack_id,omitempty"`
+	Orphaned bool           `json:"orphaned,omitempty"`
+	TreeID   *restic.ID     `json:"tree_id,omitempty"`
+	BlobID   *restic.ID     `json:"blob_id,omitempty"`
+	IndexID  *restic.ID     `json:"index_id,omitempty"`
+	Indexes  restic.IDs     `json:"indexes,omitempty"`
+	Errors   []*ErrorReport `json:"errors,omitempty"`
+}
+
+// NewErrorReport converts an error returned by the Checker to an ErrorReport.
+func NewErrorReport(err error) *ErrorReport {
+	r := &ErrorReport{Type: "error", Message: err.Error()}
+
+	switch e := errors.Cause(err).(type) {
+	case PackError:
+		r.Type = "pack"
+		r.PackID = idPtr(e.ID)
+		r.Orphaned = e.Orphaned
+	case TreeError:
+		r.Type = "tree"
+		r.TreeID = idPtr(e.ID)
+		for _, treeErr := range e.Errors {
+			r.Errors = append(r.Errors, NewErrorReport(treeErr))
+		}
+	case Error:
+		r.Type = "blob"
+		if !e.TreeID.IsNull() {
+			r.TreeID = idPtr(e.TreeID)
+		}
+		if !e.BlobID.IsNull() {
+			r.BlobID = idPtr(e.BlobID)
+		}
+	case ErrDuplicatePacks:
+		r.Type = "duplicate_packs"
+		r.PackID = idPtr(e.PackID)
+		r.Indexes = e.Indexes.List()
+	case ErrOldIndexFormat:
+		r.Type = "old_index_format"
+		r.IndexID = idPtr(e.ID)
+	}
+
+	return r
+}
+
+func idPtr(id restic.ID) *restic.ID {
+	return &id
+}
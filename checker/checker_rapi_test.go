@@ -0,0 +1,111 @@
+package checker_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/rubiojr/rapi/archiver"
+	"github.com/rubiojr/rapi/checker"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestPackSubset(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	archiver.TestSnapshot(t, repo, ".", nil)
+
+	chkr := checker.New(repo, false)
+	_, errs := chkr.LoadIndex(context.TODO())
+	test.OKs(t, errs)
+
+	allPacks := chkr.GetPacks()
+	if len(allPacks) == 0 {
+		t.Fatal("no packs found")
+	}
+
+	seen := make(map[restic.ID]int64)
+	for _, subset := range []string{"1/3", "2/3", "3/3"} {
+		packs, err := chkr.PackSubset(subset)
+		test.OK(t, err)
+		for id, size := range packs {
+			if _, ok := seen[id]; ok {
+				t.Errorf("pack %v selected in more than one subset", id.Str())
+			}
+			seen[id] = size
+		}
+	}
+	test.Equals(t, allPacks, seen)
+
+	// more groups than the values of a single ID byte
+	for id := range allPacks {
+		bucket := binary.BigEndian.Uint32(id[:4])%1000 + 1
+		packs, err := chkr.PackSubset(fmt.Sprintf("%d/1000", bucket))
+		test.OK(t, err)
+		if _, ok := packs[id]; !ok {
+			t.Errorf("pack %v not selected in subset %d/1000", id.Str(), bucket)
+		}
+	}
+
+	packs, err := chkr.PackSubset("100%")
+	test.OK(t, err)
+	test.Equals(t, allPacks, packs)
+
+	packs, err = chkr.PackSubset("0.1%")
+	test.OK(t, err)
+	test.Equals(t, 1, len(packs))
+
+	for _, subset := range []string{"", "0/3", "4/3", "1/1", "x/2", "1/4294967296", "0%", "101%", "foo%"} {
+		_, err := chkr.PackSubset(subset)
+		if err == nil {
+			t.Errorf("expected error for invalid subset %q", subset)
+		}
+	}
+}
+
+func TestNewErrorReport(t *testing.T) {
+	packID := restic.NewRandomID()
+	treeID := restic.NewRandomID()
+	blobID := restic.NewRandomID()
+	indexID := restic.NewRandomID()
+
+	var tests = []struct {
+		err  error
+		want string
+	}{
+		{
+			err:  errors.New("foo"),
+			want: `{"type":"error","message":"foo"}`,
+		},
+		{
+			err: checker.PackError{ID: packID, Orphaned: true, Err: errors.New("not referenced in any index")},
+			want: `{"type":"pack","message":"pack ` + packID.Str() + `: not referenced in any index",` +
+				`"pack_id":"` + packID.String() + `","orphaned":true}`,
+		},
+		{
+			err: checker.TreeError{ID: treeID, Errors: []error{
+				checker.Error{TreeID: treeID, BlobID: blobID, Err: errors.New("not found")},
+			}},
+			want: `{"type":"tree","message":"tree ` + treeID.Str() + `: [tree ` + treeID.Str() + `, blob ` + blobID.Str() + `: not found]",` +
+				`"tree_id":"` + treeID.String() + `","errors":[{"type":"blob","message":"tree ` + treeID.Str() + `, blob ` + blobID.Str() + `: not found",` +
+				`"tree_id":"` + treeID.String() + `","blob_id":"` + blobID.String() + `"}]}`,
+		},
+		{
+			err: checker.ErrDuplicatePacks{PackID: packID, Indexes: restic.NewIDSet(indexID)},
+			want: `{"type":"duplicate_packs","message":"pack ` + packID.Str() + ` contained in several indexes: {` + indexID.Str() + `}",` +
+				`"pack_id":"` + packID.String() + `","indexes":["` + indexID.String() + `"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		buf, err := json.Marshal(checker.NewErrorReport(tt.err))
+		test.OK(t, err)
+		test.Equals(t, tt.want, string(buf))
+	}
+}
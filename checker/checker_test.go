@@ -13,8 +13,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/rubiojr/rapi/internal/archiver"
-	"github.com/rubiojr/rapi/internal/checker"
+	"github.com/rubiojr/rapi/archiver"
+	"github.com/rubiojr/rapi/checker"
 	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/internal/hashing"
 	"github.com/rubiojr/rapi/repository"
@@ -0,0 +1,220 @@
+/*
+ * Create a new snapshot, a trimmed down version of Restic's `backup` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_backup.go
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rubiojr/rapi/archiver"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/textfile"
+	"github.com/rubiojr/rapi/internal/ui/backup"
+	"github.com/rubiojr/rapi/internal/ui/termstatus"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+	tomb "gopkg.in/tomb.v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "backup",
+		Usage:     "Create a new snapshot of files and directories",
+		ArgsUsage: "FILE/DIR [FILE/DIR] ...",
+		Action:    runBackup,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "exclude",
+				Aliases: []string{"e"},
+				Usage:   "Exclude a `pattern` (can be specified multiple times)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude-file",
+				Usage: "Read exclude patterns from a `file` (can be specified multiple times)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tag",
+				Usage: "Add a `tag` for the new snapshot (can be specified multiple times)",
+			},
+			&cli.StringFlag{
+				Name:    "host",
+				Aliases: []string{"H"},
+				Usage:   "Set the `hostname` for the snapshot manually",
+			},
+			&cli.StringFlag{
+				Name:  "parent",
+				Usage: "Use this parent `snapshot` (default: last snapshot in the repo that has the same target files/directories)",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Do not upload or write any data, just show what would be done",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print progress and summary as JSON",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runBackup(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() == 0 {
+		return errors.Fatal("nothing to backup, please specify target files/dirs")
+	}
+
+	excludes := c.StringSlice("exclude")
+	for _, filename := range c.StringSlice("exclude-file") {
+		patterns, err := readExcludePatterns(filename)
+		if err != nil {
+			return err
+		}
+		excludes = append(excludes, patterns...)
+	}
+
+	hostname := c.String("host")
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	targets := c.Args().Slice()
+	parentID, err := findParentSnapshot(ctx, c.String("parent"), targets, hostname)
+	if err != nil {
+		return err
+	}
+
+	var t tomb.Tomb
+	term := termstatus.New(os.Stdout, os.Stderr, false)
+	t.Go(func() error { term.Run(t.Context(ctx)); return nil })
+	defer func() {
+		t.Kill(nil)
+		_ = t.Wait()
+	}()
+
+	var printer backup.ProgressPrinter
+	if c.Bool("json") {
+		printer = backup.NewJSONProgress(term, 1)
+	} else {
+		printer = backup.NewTextProgress(term, 1)
+	}
+	progress := backup.NewProgress(printer)
+
+	if c.Bool("dry-run") {
+		rapiRepo.SetDryRun()
+		progress.SetDryRun()
+	}
+
+	if !c.Bool("json") && !parentID.IsNull() {
+		printer.V("using parent snapshot %v\n", parentID.Str())
+	}
+
+	// status lines are only useful on a terminal, JSON consumers get
+	// an update every second
+	if c.Bool("json") {
+		progress.SetMinUpdatePause(time.Second)
+	} else if !stdoutIsTerminal() {
+		progress.SetMinUpdatePause(0)
+	}
+
+	progressCtx, cancelProgress := context.WithCancel(ctx)
+	defer cancelProgress()
+	go func() {
+		_ = progress.Run(progressCtx)
+	}()
+
+	_, id, err := archiver.Backup(ctx, rapiRepo, targets, archiver.BackupOptions{
+		Excludes: excludes,
+		Tags:     restic.TagList(c.StringSlice("tag")),
+		Hostname: hostname,
+		Parent:   parentID,
+		Progress: archiver.BackupProgress{
+			StartFile:    progress.StartFile,
+			CompleteBlob: progress.CompleteBlob,
+			CompleteItem: progress.CompleteItem,
+			ReportTotal:  progress.ReportTotal,
+			Error:        progress.Error,
+			ScannerError: progress.ScannerError,
+		},
+	})
+
+	cancelProgress()
+	if err != nil {
+		return errors.Fatalf("unable to save snapshot: %v", err)
+	}
+
+	progress.Finish(id)
+	if !c.Bool("json") {
+		if c.Bool("dry-run") {
+			printer.P("\nsnapshot not saved (dry run)\n")
+		} else {
+			printer.P("\nsnapshot %s saved\n", id.Str())
+		}
+	}
+
+	return nil
+}
+
+// findParentSnapshot returns the ID of the snapshot given in parent or, when
+// empty, the latest snapshot with the same host and absolute target paths.
+func findParentSnapshot(ctx context.Context, parent string, targets []string, hostname string) (restic.ID, error) {
+	if parent != "" {
+		id, err := restic.FindSnapshot(ctx, rapiRepo, parent)
+		if err != nil {
+			return restic.ID{}, errors.Fatalf("invalid id %q: %v", parent, err)
+		}
+		return id, nil
+	}
+
+	absTargets := make([]string, 0, len(targets))
+	for _, target := range targets {
+		abs, err := filepath.Abs(target)
+		if err != nil {
+			return restic.ID{}, err
+		}
+		absTargets = append(absTargets, filepath.Clean(abs))
+	}
+
+	id, err := restic.FindLatestSnapshot(ctx, rapiRepo, absTargets, []restic.TagList{}, []string{hostname}, nil)
+	if err == restic.ErrNoSnapshotFound {
+		return restic.ID{}, nil
+	}
+
+	return id, err
+}
+
+// readExcludePatterns reads the exclude patterns from filename, one per line.
+// Empty lines and lines starting with # are ignored, environment variables
+// are expanded.
+func readExcludePatterns(filename string) ([]string, error) {
+	data, err := textfile.Read(filename)
+	if err != nil {
+		return nil, errors.Fatalf("unable to read exclude file: %v", err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, os.ExpandEnv(line))
+	}
+
+	return patterns, scanner.Err()
+}
@@ -54,6 +54,20 @@ func init() {
 				Before: func(c *cli.Context) error {
 					return setupApp(c)
 				},
+			}, &cli.Command{
+				Name:   "lock",
+				Action: runCatLock,
+				Flags:  []cli.Flag{},
+				Before: func(c *cli.Context) error {
+					return setupApp(c)
+				},
+			}, &cli.Command{
+				Name:   "pack",
+				Action: runCatPack,
+				Flags:  []cli.Flag{},
+				Before: func(c *cli.Context) error {
+					return setupApp(c)
+				},
 			}, &cli.Command{
 				Name:   "masterkey",
 				Action: runCatMasterKey,
@@ -99,6 +113,20 @@ func runCatIndex(c *cli.Context) error {
 	return runCatFor(c, "index")
 }
 
+func runCatLock(c *cli.Context) error {
+	if c.Args().Get(0) == "" {
+		return errors.Fatal("ID not specified")
+	}
+	return runCatFor(c, "lock")
+}
+
+func runCatPack(c *cli.Context) error {
+	if c.Args().Get(0) == "" {
+		return errors.Fatal("ID not specified")
+	}
+	return runCatFor(c, "pack")
+}
+
 func runCatConfig(c *cli.Context) error {
 	return runCatFor(c, "config")
 }
@@ -112,15 +140,23 @@ func runCatFor(c *cli.Context, tpe string) error {
 	if tpe != "masterkey" && tpe != "config" {
 		id, err = restic.ParseID(arg0)
 		if err != nil {
-			if tpe != "snapshot" {
+			switch tpe {
+			case "snapshot":
+				// find snapshot id with prefix
+				id, err = restic.FindSnapshot(ctx, rapiRepo, arg0)
+				if err != nil {
+					return errors.Fatalf("could not find snapshot: %v\n", err)
+				}
+			case "lock", "pack":
+				// lock and pack IDs are usually copied from listings, find
+				// them by prefix too
+				id, err = findFile(ctx, tpe, arg0)
+				if err != nil {
+					return err
+				}
+			default:
 				return errors.Fatalf("unable to parse ID: %v\n", err)
 			}
-
-			// find snapshot id with prefix
-			id, err = restic.FindSnapshot(ctx, rapiRepo, arg0)
-			if err != nil {
-				return errors.Fatalf("could not find snapshot: %v\n", err)
-			}
 		}
 	}
 
@@ -197,15 +233,6 @@ func runCatFor(c *cli.Context, tpe string) error {
 
 		rapi.Println(string(buf))
 		return nil
-	}
-
-	// load index, handle all the other types
-	err = rapiRepo.LoadIndex(ctx)
-	if err != nil {
-		return err
-	}
-
-	switch tpe {
 	case "pack":
 		h := restic.Handle{Type: restic.PackFile, Name: id.String()}
 		buf, err := backend.LoadAll(ctx, nil, rapiRepo.Backend(), h)
@@ -220,7 +247,15 @@ func runCatFor(c *cli.Context, tpe string) error {
 
 		_, err = os.Stdout.Write(buf)
 		return err
+	}
 
+	// load index, handle all the other types
+	err = rapiRepo.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch tpe {
 	case "blob":
 		for _, t := range []restic.BlobType{restic.DataBlob, restic.TreeBlob} {
 			if !rapiRepo.Index().Has(restic.BlobHandle{ID: id, Type: t}) {
@@ -242,3 +277,18 @@ func runCatFor(c *cli.Context, tpe string) error {
 		return errors.Fatal("invalid type")
 	}
 }
+
+// findFile resolves a unique prefix of the ID of a lock or pack file.
+func findFile(ctx context.Context, tpe string, prefix string) (restic.ID, error) {
+	t := restic.LockFile
+	if tpe == "pack" {
+		t = restic.PackFile
+	}
+
+	name, err := restic.Find(ctx, rapiRepo.Backend(), t, prefix)
+	if err != nil {
+		return restic.ID{}, errors.Fatalf("could not find %s %q: %v", tpe, prefix, err)
+	}
+
+	return restic.ParseID(name)
+}
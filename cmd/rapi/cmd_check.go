@@ -0,0 +1,177 @@
+/*
+ * Check the repository for errors, Restic's `check` command with
+ * structured JSON output.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_check.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/checker"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "check",
+		Usage:  "Check the repository for errors",
+		Action: runCheck,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "read-data",
+				Usage: "Read all data blobs",
+			},
+			&cli.StringFlag{
+				Name:  "read-data-subset",
+				Usage: "Read a `subset` of data packs, specified as 'n/m' for a specific part, or as a percentage, e.g. '10%'",
+			},
+			&cli.BoolFlag{
+				Name:  "check-unused",
+				Usage: "Find unused blobs",
+			},
+			&cli.BoolFlag{
+				Name:  "with-cache",
+				Usage: "Use the local cache, data read from it is not verified",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the errors found as JSON",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			// the cache may hide damaged files in the repository
+			if !c.Bool("with-cache") {
+				globalOptions.NoCache = true
+			}
+			return setupAppLocked(c, true)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// checkReport is printed by `check --json`.
+type checkReport struct {
+	Hints       []*checker.ErrorReport `json:"hints"`
+	Errors      []*checker.ErrorReport `json:"errors"`
+	UnusedBlobs restic.BlobHandles     `json:"unused_blobs,omitempty"`
+	PacksRead   int                    `json:"packs_read"`
+}
+
+func runCheck(c *cli.Context) error {
+	ctx := context.Background()
+	jsonOutput := c.Bool("json")
+	if c.Bool("read-data") && c.String("read-data-subset") != "" {
+		return errors.Fatal("check flags --read-data and --read-data-subset cannot be used together")
+	}
+
+	report := &checkReport{
+		Hints:  []*checker.ErrorReport{},
+		Errors: []*checker.ErrorReport{},
+	}
+	printf := func(format string, args ...interface{}) {
+		if !jsonOutput {
+			rapi.Printf(format, args...)
+		}
+	}
+	addError := func(err error) {
+		report.Errors = append(report.Errors, checker.NewErrorReport(err))
+		if !jsonOutput {
+			rapi.Warnf("error: %v\n", err)
+		}
+	}
+	addHint := func(err error) {
+		report.Hints = append(report.Hints, checker.NewErrorReport(err))
+		if !jsonOutput {
+			rapi.Printf("%v\n", err)
+		}
+	}
+	collect := func(fn func(errChan chan<- error)) {
+		errChan := make(chan error)
+		go fn(errChan)
+		for err := range errChan {
+			if checker.IsOrphanedPack(err) {
+				addHint(err)
+				continue
+			}
+			addError(err)
+		}
+	}
+
+	chkr := checker.New(rapiRepo, c.Bool("check-unused"))
+
+	printf("load indexes\n")
+	hints, errs := chkr.LoadIndex(ctx)
+	for _, hint := range hints {
+		addHint(hint)
+	}
+	for _, err := range errs {
+		addError(err)
+	}
+	if len(errs) > 0 {
+		return finishCheck(report, jsonOutput, "LoadIndex returned errors")
+	}
+
+	printf("check all packs\n")
+	collect(func(errChan chan<- error) { chkr.Packs(ctx, errChan) })
+
+	printf("check snapshots, trees and blobs\n")
+	collect(func(errChan chan<- error) { chkr.Structure(ctx, nil, errChan) })
+
+	if c.Bool("check-unused") {
+		for _, h := range chkr.UnusedBlobs(ctx) {
+			report.UnusedBlobs = append(report.UnusedBlobs, h)
+			printf("unused blob %v\n", h)
+		}
+	}
+
+	var packs map[restic.ID]int64
+	switch {
+	case c.Bool("read-data"):
+		packs = chkr.GetPacks()
+		printf("read all data\n")
+	case c.String("read-data-subset") != "":
+		subset := c.String("read-data-subset")
+		var err error
+		packs, err = chkr.PackSubset(subset)
+		if err != nil {
+			return errors.Fatalf("%v", err)
+		}
+		printf("read %s of data packs (%d packs)\n", subset, len(packs))
+	}
+
+	if packs != nil {
+		report.PacksRead = len(packs)
+		collect(func(errChan chan<- error) { chkr.ReadPacks(ctx, packs, nil, errChan) })
+	}
+
+	return finishCheck(report, jsonOutput, "repository contains errors")
+}
+
+// finishCheck prints the report when JSON output is requested and returns
+// an error when the check found errors.
+func finishCheck(report *checkReport, jsonOutput bool, errMsg string) error {
+	if jsonOutput {
+		buf, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		rapi.Println(string(buf))
+	}
+
+	if len(report.Errors) > 0 {
+		return errors.Fatal(errMsg)
+	}
+
+	if !jsonOutput {
+		rapi.Printf("no errors were found\n")
+	}
+	return nil
+}
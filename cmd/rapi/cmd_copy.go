@@ -0,0 +1,87 @@
+/*
+ * Copy snapshots from one repository to another, Restic's `copy` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_copy.go
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "copy",
+		Usage:     "Copy snapshots from another repository",
+		ArgsUsage: "[snapshot ID...]",
+		Action:    runCopy,
+		Flags:     append(fromRepoFlags(), snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runCopy(c *cli.Context) error {
+	ctx := context.Background()
+
+	srcRepo, err := openFromRepo(c)
+	if err != nil {
+		return err
+	}
+
+	if srcRepo.Config().ID == rapiRepo.Config().ID {
+		return errors.Fatal("source and destination are the same repository")
+	}
+
+	if err = lockRepo(ctx, srcRepo, false); err != nil {
+		return err
+	}
+
+	if srcRepo.Config().ChunkerPolynomial != rapiRepo.Config().ChunkerPolynomial {
+		rapi.Warnf("the repositories use different chunker parameters, data backed up to the destination will not deduplicate with the copied snapshots\n")
+	}
+
+	if err = srcRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+	if err = rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	snapshots, err := findSnapshots(ctx, srcRepo, c)
+	if err != nil {
+		return err
+	}
+
+	dstSnapshots, err := restic.FindFilteredSnapshots(ctx, rapiRepo, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, sn := range snapshots {
+		rapi.Printf("snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time.Local().Format(rapi.TimeFormat))
+
+		if dstSn := repository.FindCopy(dstSnapshots, sn); dstSn != nil {
+			rapi.Printf("  skipping, already copied to snapshot %s\n", dstSn.ID().Str())
+			continue
+		}
+
+		id, err := repository.CopySnapshot(ctx, srcRepo, rapiRepo, sn)
+		if err != nil {
+			return err
+		}
+		rapi.Printf("  copied to snapshot %s\n", id.Str())
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Show the differences between two snapshots, Restic's `diff` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_diff.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/diff"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "diff",
+		Usage:     "Show differences between two snapshots",
+		ArgsUsage: "<snapshot ID|latest> <snapshot ID|latest>",
+		Action:    runDiff,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "metadata",
+				Usage: "Print changes in metadata",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the changes and statistics as JSON",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+type diffOutput struct {
+	SourceSnapshot string        `json:"source_snapshot"`
+	TargetSnapshot string        `json:"target_snapshot"`
+	Changes        []diff.Change `json:"changes"`
+	Statistics     *diff.Stats   `json:"statistics"`
+}
+
+func loadSnapshotArg(ctx context.Context, repo restic.Repository, s string) (*restic.Snapshot, error) {
+	id, err := findSnapshot(ctx, repo, s)
+	if err != nil {
+		return nil, err
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, repo, id)
+	if err != nil {
+		return nil, errors.Fatalf("loading snapshot %q failed: %v", id.Str(), err)
+	}
+
+	return sn, nil
+}
+
+func runDiff(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() != 2 {
+		return errors.Fatal("specify two snapshot IDs")
+	}
+
+	sn1, err := loadSnapshotArg(ctx, rapiRepo, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	sn2, err := loadSnapshotArg(ctx, rapiRepo, c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	if err = rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	jsonOutput := c.Bool("json")
+	out := diffOutput{
+		SourceSnapshot: sn1.ID().String(),
+		TargetSnapshot: sn2.ID().String(),
+		Changes:        []diff.Change{},
+	}
+
+	if !jsonOutput {
+		rapi.Printf("comparing snapshot %v to %v:\n\n", sn1.ID().Str(), sn2.ID().Str())
+	}
+
+	opts := diff.Options{
+		ShowMetadata: c.Bool("metadata"),
+		Change: func(change diff.Change) {
+			if jsonOutput {
+				out.Changes = append(out.Changes, change)
+				return
+			}
+			rapi.Printf("%-5s%v\n", change.Modifier, change.Path)
+		},
+	}
+
+	stats, err := diff.Snapshots(ctx, rapiRepo, sn1, sn2, opts)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		out.Statistics = stats
+		buf, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		rapi.Println(string(buf))
+		return nil
+	}
+
+	rapi.Printf("\n")
+	rapi.Printf("Files:       %5d new, %5d removed, %5d changed\n", stats.Added.Files, stats.Removed.Files, stats.ChangedFiles)
+	rapi.Printf("Dirs:        %5d new, %5d removed\n", stats.Added.Dirs, stats.Removed.Dirs)
+	rapi.Printf("Others:      %5d new, %5d removed\n", stats.Added.Others, stats.Removed.Others)
+	rapi.Printf("Data Blobs:  %5d new, %5d removed\n", stats.Added.DataBlobs, stats.Removed.DataBlobs)
+	rapi.Printf("Tree Blobs:  %5d new, %5d removed\n", stats.Added.TreeBlobs, stats.Removed.TreeBlobs)
+	rapi.Printf("  Added:   %-5s\n", humanize.Bytes(stats.Added.Bytes))
+	rapi.Printf("  Removed: %-5s\n", humanize.Bytes(stats.Removed.Bytes))
+
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+ * Print a backed-up file or directory to stdout, Restic's `dump` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_dump.go
+ */
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rubiojr/rapi/dump"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "dump",
+		Usage:     "Print a backed-up file to stdout",
+		ArgsUsage: "<snapshot ID|latest> <file|dir>",
+		Action:    runDump,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "archive",
+				Aliases: []string{"a"},
+				Usage:   "Set archive `format` as \"tar\" or \"zip\"",
+				Value:   "tar",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runDump(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() != 2 {
+		return errors.Fatal("no file and no snapshot ID specified")
+	}
+
+	format := c.String("archive")
+	if err := dump.CheckFormat(format); err != nil {
+		return errors.Fatal(err.Error())
+	}
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	id, err := findSnapshot(ctx, rapiRepo, c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, rapiRepo, id)
+	if err != nil {
+		return errors.Fatalf("loading snapshot %q failed: %v", id.Str(), err)
+	}
+
+	tree, err := rapiRepo.LoadTree(ctx, *sn.Tree)
+	if err != nil {
+		return errors.Fatalf("loading tree for snapshot %q failed: %v", id.Str(), err)
+	}
+
+	node, err := dump.FindNode(ctx, rapiRepo, tree, c.Args().Get(1))
+	if err != nil {
+		return errors.Fatalf("cannot dump file: %v", err)
+	}
+
+	d := dump.New(format, rapiRepo, os.Stdout)
+
+	// directories are written as archives, a nil node is the root of the
+	// snapshot
+	if (node == nil || dump.IsDir(node)) && stdoutIsTerminal() {
+		return errors.Fatal("stdout is the terminal, please redirect output")
+	}
+
+	if node == nil {
+		err = d.DumpTree(ctx, tree, "/")
+	} else {
+		err = d.DumpNode(ctx, node)
+	}
+	if err != nil {
+		return errors.Fatalf("cannot dump file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,431 @@
+/*
+ * Find files and blobs in all snapshots, Restic's `find` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_find.go
+ */
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/filter"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "find",
+		Usage:     "Find a file, a directory or restic IDs",
+		ArgsUsage: "<pattern|ID>...",
+		Action:    runFind,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "oldest",
+				Aliases: []string{"O"},
+				Usage:   "Oldest modification date/time, as YYYY-MM-DD[ HH:MM[:SS]]",
+			},
+			&cli.StringFlag{
+				Name:    "newest",
+				Aliases: []string{"N"},
+				Usage:   "Newest modification date/time, as YYYY-MM-DD[ HH:MM[:SS]]",
+			},
+			&cli.BoolFlag{
+				Name:  "blob",
+				Usage: "Pattern is a blob-ID",
+			},
+			&cli.BoolFlag{
+				Name:  "tree",
+				Usage: "Pattern is a tree-ID",
+			},
+			&cli.BoolFlag{
+				Name:  "pack",
+				Usage: "Pattern is a pack-ID",
+			},
+			&cli.BoolFlag{
+				Name:    "ignore-case",
+				Aliases: []string{"i"},
+				Usage:   "Ignore case for pattern",
+			},
+			&cli.BoolFlag{
+				Name:    "long",
+				Aliases: []string{"l"},
+				Usage:   "Use a long listing format showing size and mode",
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+const shortStr = 8 // Length of short IDs: 4 bytes as hex strings
+
+type findPattern struct {
+	oldest, newest time.Time
+	pattern        []string
+	ignoreCase     bool
+}
+
+func parseTime(str string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Fatalf("unable to parse time: %q", str)
+}
+
+// finder searches the trees of the snapshots for nodes matching the
+// patterns, or for the nodes referencing the blobs and trees in blobIDs
+// and treeIDs.
+type finder struct {
+	repo        restic.Repository
+	pat         findPattern
+	long        bool
+	ignoreTrees restic.IDSet
+	blobIDs     map[string]struct{}
+	treeIDs     map[string]struct{}
+
+	// snapshot whose matches are being printed
+	sn   *restic.Snapshot
+	hits int
+}
+
+// printHeader prints the snapshot before its first match.
+func (f *finder) printHeader() {
+	if f.hits == 0 {
+		rapi.Printf("Found matching entries in snapshot %s from %s\n", f.sn.ID().Str(), f.sn.Time.Local().Format(rapi.TimeFormat))
+	}
+	f.hits++
+}
+
+func (f *finder) startSnapshot(sn *restic.Snapshot) {
+	if f.hits > 0 {
+		rapi.Printf("\n")
+	}
+	f.sn = sn
+	f.hits = 0
+}
+
+func (f *finder) findInSnapshot(ctx context.Context, sn *restic.Snapshot) error {
+	debug.Log("searching in snapshot %s\n  for entries within [%s %s]", sn.ID(), f.pat.oldest, f.pat.newest)
+
+	if sn.Tree == nil {
+		return errors.Errorf("snapshot %v has no tree", sn.ID().Str())
+	}
+
+	f.startSnapshot(sn)
+	return walker.Walk(ctx, f.repo, *sn.Tree, f.ignoreTrees, func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			debug.Log("Error loading tree %v: %v", parentTreeID, err)
+
+			rapi.Warnf("Unable to load tree %s\n ... which belongs to snapshot %s.\n", parentTreeID, sn.ID())
+
+			return false, walker.ErrSkipNode
+		}
+
+		if node == nil {
+			return false, nil
+		}
+
+		normalizedNodepath := nodepath
+		if f.pat.ignoreCase {
+			normalizedNodepath = strings.ToLower(nodepath)
+		}
+
+		var foundMatch bool
+
+		for _, pat := range f.pat.pattern {
+			found, err := filter.Match(pat, normalizedNodepath)
+			if err != nil {
+				return false, err
+			}
+			if found {
+				foundMatch = true
+				break
+			}
+		}
+
+		var (
+			ignoreIfNoMatch = true
+			errIfNoMatch    error
+		)
+		if node.Type == "dir" {
+			var childMayMatch bool
+			for _, pat := range f.pat.pattern {
+				mayMatch, err := filter.ChildMatch(pat, normalizedNodepath)
+				if err != nil {
+					return false, err
+				}
+				if mayMatch {
+					childMayMatch = true
+					break
+				}
+			}
+
+			if !childMayMatch {
+				ignoreIfNoMatch = true
+				errIfNoMatch = walker.ErrSkipNode
+			} else {
+				ignoreIfNoMatch = false
+			}
+		}
+
+		if !foundMatch {
+			return ignoreIfNoMatch, errIfNoMatch
+		}
+
+		if !f.pat.oldest.IsZero() && node.ModTime.Before(f.pat.oldest) {
+			debug.Log("    ModTime is older than %s\n", f.pat.oldest)
+			return ignoreIfNoMatch, errIfNoMatch
+		}
+
+		if !f.pat.newest.IsZero() && node.ModTime.After(f.pat.newest) {
+			debug.Log("    ModTime is newer than %s\n", f.pat.newest)
+			return ignoreIfNoMatch, errIfNoMatch
+		}
+
+		debug.Log("    found match\n")
+		f.printHeader()
+		rapi.Printf("%s\n", formatNode(nodepath, node, f.long))
+
+		// a matching node may be matched in a different path in another
+		// snapshot, don't ignore its tree
+		return false, nil
+	})
+}
+
+func (f *finder) findIDs(ctx context.Context, sn *restic.Snapshot) error {
+	debug.Log("searching IDs in snapshot %s", sn.ID())
+
+	if sn.Tree == nil {
+		return errors.Errorf("snapshot %v has no tree", sn.ID().Str())
+	}
+
+	return walker.Walk(ctx, f.repo, *sn.Tree, f.ignoreTrees, func(parentTreeID restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			debug.Log("Error loading tree %v: %v", parentTreeID, err)
+
+			rapi.Warnf("Unable to load tree %s\n ... which belongs to snapshot %s.\n", parentTreeID, sn.ID())
+
+			return false, walker.ErrSkipNode
+		}
+
+		if node == nil {
+			return false, nil
+		}
+
+		if node.Type == "dir" && f.treeIDs != nil {
+			treeID := node.Subtree
+			found := false
+			if _, ok := f.treeIDs[treeID.Str()]; ok {
+				found = true
+			} else if _, ok := f.treeIDs[treeID.String()]; ok {
+				found = true
+			}
+			if found {
+				f.printObject("tree", treeID.String(), nodepath, "", sn)
+				// only the first occurrence of a tree is printed, its
+				// contents are identical in all snapshots
+				return true, nil
+			}
+		}
+
+		if node.Type == "file" && f.blobIDs != nil {
+			for _, id := range node.Content {
+				idStr := id.String()
+				if _, ok := f.blobIDs[idStr]; !ok {
+					// Look for short ID form
+					if _, ok := f.blobIDs[idStr[:shortStr]]; !ok {
+						continue
+					}
+					// Replace the short ID with the long one
+					f.blobIDs[idStr] = struct{}{}
+					delete(f.blobIDs, idStr[:shortStr])
+				}
+				f.printObject("blob", idStr, nodepath, parentTreeID.String(), sn)
+			}
+		}
+
+		return false, nil
+	})
+}
+
+func (f *finder) printObject(kind, id, nodepath, treeID string, sn *restic.Snapshot) {
+	rapi.Printf("Found %s %s\n", kind, id)
+	if kind == "blob" {
+		rapi.Printf(" ... in file %s\n", nodepath)
+		rapi.Printf("     (tree %s)\n", treeID)
+	} else {
+		rapi.Printf(" ... path %s\n", nodepath)
+	}
+	rapi.Printf(" ... in snapshot %s (%s)\n", sn.ID().Str(), sn.Time.Local().Format(rapi.TimeFormat))
+}
+
+// packsToBlobs converts the list of pack IDs to a list of blob IDs that
+// are contained in the packs.
+func (f *finder) packsToBlobs(ctx context.Context, packs []string) error {
+	packIDs := make(map[string]struct{})
+	for _, p := range packs {
+		packIDs[p] = struct{}{}
+	}
+	if f.blobIDs == nil {
+		f.blobIDs = make(map[string]struct{})
+	}
+
+	allPacksFound := false
+	packsFound := 0
+
+	debug.Log("Looking for packs...")
+	err := f.repo.List(ctx, restic.PackFile, func(id restic.ID, size int64) error {
+		if allPacksFound {
+			return nil
+		}
+		idStr := id.String()
+		if _, ok := packIDs[idStr]; !ok {
+			// Look for short ID form
+			if _, ok := packIDs[idStr[:shortStr]]; !ok {
+				return nil
+			}
+		}
+		debug.Log("Found pack %s", idStr)
+		blobs, _, err := f.repo.ListPack(ctx, id, size)
+		if err != nil {
+			return err
+		}
+		for _, b := range blobs {
+			f.blobIDs[b.ID.String()] = struct{}{}
+		}
+		// Stop searching when all packs have been found
+		packsFound++
+		if packsFound >= len(packIDs) {
+			allPacksFound = true
+		}
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !allPacksFound {
+		return errors.Fatal("unable to find all specified pack(s)")
+	}
+
+	debug.Log("%d blobs found", len(f.blobIDs))
+	return nil
+}
+
+func idSet(ids []string) map[string]struct{} {
+	m := make(map[string]struct{})
+	for _, id := range ids {
+		m[id] = struct{}{}
+	}
+	return m
+}
+
+func runFind(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() == 0 {
+		return errors.Fatal("wrong number of arguments")
+	}
+
+	var err error
+	pat := findPattern{
+		pattern:    c.Args().Slice(),
+		ignoreCase: c.Bool("ignore-case"),
+	}
+	if pat.ignoreCase {
+		for i := range pat.pattern {
+			pat.pattern[i] = strings.ToLower(pat.pattern[i])
+		}
+	}
+
+	if s := c.String("oldest"); s != "" {
+		if pat.oldest, err = parseTime(s); err != nil {
+			return err
+		}
+	}
+
+	if s := c.String("newest"); s != "" {
+		if pat.newest, err = parseTime(s); err != nil {
+			return err
+		}
+	}
+
+	// Check at most only one kind of IDs is provided: currently we
+	// can't mix types
+	blob, tree, pack := c.Bool("blob"), c.Bool("tree"), c.Bool("pack")
+	if (blob && tree) || (blob && pack) || (tree && pack) {
+		return errors.Fatal("cannot have several ID types")
+	}
+
+	if err = rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	f := &finder{
+		repo:        rapiRepo,
+		pat:         pat,
+		long:        c.Bool("long"),
+		ignoreTrees: restic.NewIDSet(),
+	}
+
+	switch {
+	case blob:
+		f.blobIDs = idSet(pat.pattern)
+	case tree:
+		f.treeIDs = idSet(pat.pattern)
+	case pack:
+		if err = f.packsToBlobs(ctx, pat.pattern); err != nil {
+			return err
+		}
+	}
+
+	hosts, tags, paths := snapshotFilter(c)
+	var snapshots restic.Snapshots
+	err = restic.ForAllSnapshots(ctx, rapiRepo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			rapi.Warnf("could not load snapshot %v: %v\n", id.Str(), err)
+			return nil
+		}
+
+		if !sn.HasHostname(hosts) || !sn.HasTagList(tags) || !sn.HasPaths(paths) {
+			return nil
+		}
+
+		snapshots = append(snapshots, sn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// search the newest snapshots first
+	sort.Sort(snapshots)
+
+	for _, sn := range snapshots {
+		if f.blobIDs != nil || f.treeIDs != nil {
+			err = f.findIDs(ctx, sn)
+		} else {
+			err = f.findInSnapshot(ctx, sn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
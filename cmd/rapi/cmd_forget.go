@@ -0,0 +1,253 @@
+/*
+ * Remove snapshots according to a policy, Restic's `forget` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_forget.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	flags := []cli.Flag{
+		&cli.IntFlag{
+			Name:    "keep-last",
+			Aliases: []string{"l"},
+			Usage:   "Keep the last `n` snapshots",
+		},
+		&cli.IntFlag{
+			Name:  "keep-hourly",
+			Usage: "Keep the last `n` hourly snapshots",
+		},
+		&cli.IntFlag{
+			Name:    "keep-daily",
+			Aliases: []string{"d"},
+			Usage:   "Keep the last `n` daily snapshots",
+		},
+		&cli.IntFlag{
+			Name:    "keep-weekly",
+			Aliases: []string{"w"},
+			Usage:   "Keep the last `n` weekly snapshots",
+		},
+		&cli.IntFlag{
+			Name:    "keep-monthly",
+			Aliases: []string{"m"},
+			Usage:   "Keep the last `n` monthly snapshots",
+		},
+		&cli.IntFlag{
+			Name:    "keep-yearly",
+			Aliases: []string{"y"},
+			Usage:   "Keep the last `n` yearly snapshots",
+		},
+		&cli.GenericFlag{
+			Name:  "keep-within",
+			Usage: "Keep snapshots that are newer than `duration` (eg. 1y5m7d2h) relative to the latest snapshot",
+			Value: &restic.Duration{},
+		},
+		&cli.GenericFlag{
+			Name:  "keep-tag",
+			Usage: "Keep snapshots with this `taglist` (can be specified multiple times)",
+			Value: &restic.TagLists{},
+		},
+		&cli.StringFlag{
+			Name:    "group-by",
+			Aliases: []string{"g"},
+			Usage:   "String for grouping snapshots by host,paths,tags",
+			Value:   "host,paths",
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "Do not delete anything, just print what would be done",
+		},
+		&cli.BoolFlag{
+			Name:  "prune",
+			Usage: "Automatically run the 'prune' command if snapshots have been removed",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print the groups of kept and removed snapshots as JSON",
+		},
+	}
+	flags = append(flags, snapshotFilterFlags()...)
+	flags = append(flags, pruneFlags()...)
+
+	cmd := &cli.Command{
+		Name:      "forget",
+		Usage:     "Remove snapshots from the repository",
+		ArgsUsage: "[snapshot ID] ...",
+		Action:    runForget,
+		Flags:     flags,
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, true)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// forgetGroup is printed by `forget --json` for every group of snapshots.
+type forgetGroup struct {
+	Tags    []string            `json:"tags"`
+	Host    string              `json:"host"`
+	Paths   []string            `json:"paths"`
+	Keep    restic.Snapshots    `json:"keep"`
+	Remove  restic.Snapshots    `json:"remove"`
+	Reasons []restic.KeepReason `json:"reasons"`
+}
+
+func runForget(c *cli.Context) error {
+	ctx := context.Background()
+	jsonOutput := c.Bool("json")
+	dryRun := c.Bool("dry-run")
+
+	var pruneOpts pruneOptions
+	if c.Bool("prune") {
+		var err error
+		pruneOpts, err = parsePruneOptions(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	policy := restic.ExpirePolicy{
+		Last:    c.Int("keep-last"),
+		Hourly:  c.Int("keep-hourly"),
+		Daily:   c.Int("keep-daily"),
+		Weekly:  c.Int("keep-weekly"),
+		Monthly: c.Int("keep-monthly"),
+		Yearly:  c.Int("keep-yearly"),
+	}
+	if d, ok := c.Generic("keep-within").(*restic.Duration); ok {
+		policy.Within = *d
+	}
+	if l, ok := c.Generic("keep-tag").(*restic.TagLists); ok {
+		policy.Tags = *l
+	}
+
+	printf := func(format string, args ...interface{}) {
+		if !jsonOutput {
+			rapi.Printf(format, args...)
+		}
+	}
+
+	removeSnIDs := restic.NewIDSet()
+
+	if c.NArg() > 0 {
+		// explicitly given snapshots are removed without applying the policy
+		for _, s := range c.Args().Slice() {
+			id, err := restic.FindSnapshot(ctx, rapiRepo, s)
+			if err != nil {
+				rapi.Warnf("could not find snapshot %q: %v\n", s, err)
+				continue
+			}
+			removeSnIDs.Insert(id)
+		}
+	} else {
+		if policy.Empty() {
+			return errors.Fatal("no policy was specified, no snapshots will be removed")
+		}
+
+		hosts, tags, paths := snapshotFilter(c)
+		snapshots, err := restic.FindFilteredSnapshots(ctx, rapiRepo, hosts, tags, paths)
+		if err != nil {
+			return err
+		}
+
+		snapshotGroups, _, err := restic.GroupSnapshots(snapshots, c.String("group-by"))
+		if err != nil {
+			return err
+		}
+
+		printf("Applying Policy: %v\n", policy)
+
+		// print the groups in a stable order
+		keys := make([]string, 0, len(snapshotGroups))
+		for k := range snapshotGroups {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var jsonGroups []forgetGroup
+		for _, k := range keys {
+			var key restic.SnapshotGroupKey
+			if err := json.Unmarshal([]byte(k), &key); err != nil {
+				return err
+			}
+
+			keep, remove, reasons := restic.ApplyPolicy(snapshotGroups[k], policy)
+
+			if jsonOutput {
+				jsonGroups = append(jsonGroups, forgetGroup{
+					Tags:    key.Tags,
+					Host:    key.Hostname,
+					Paths:   key.Paths,
+					Keep:    keep,
+					Remove:  remove,
+					Reasons: reasons,
+				})
+			} else {
+				if desc := groupKeyString(key); desc != "" {
+					printf("snapshots for %s:\n\n", desc)
+				}
+				if len(keep) != 0 {
+					printf("keep %d snapshots:\n", len(keep))
+					printSnapshots(os.Stdout, keep, reasons)
+					printf("\n")
+				}
+				if len(remove) != 0 {
+					printf("remove %d snapshots:\n", len(remove))
+					printSnapshots(os.Stdout, remove, nil)
+					printf("\n")
+				}
+			}
+
+			for _, sn := range remove {
+				removeSnIDs.Insert(*sn.ID())
+			}
+		}
+
+		if jsonOutput {
+			buf, err := json.MarshalIndent(jsonGroups, "", "  ")
+			if err != nil {
+				return err
+			}
+			rapi.Println(string(buf))
+		}
+	}
+
+	if len(removeSnIDs) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		printf("would have removed the following snapshots:\n")
+		for id := range removeSnIDs {
+			printf("  %v\n", id.Str())
+		}
+		return nil
+	}
+
+	printf("removing %d snapshots\n", len(removeSnIDs))
+	err := deleteFilesChecked(ctx, rapiRepo, removeSnIDs, restic.SnapshotFile, false)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("prune") {
+		printf("\n")
+		return runPruneWithRepo(ctx, rapiRepo, pruneOpts)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Rebuild the repository index, Restic's `rebuild-index` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_rebuild_index.go
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:  "index",
+		Usage: "Manage the repository index",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "rebuild",
+				Usage:  "Build a new index from the pack files",
+				Action: runIndexRebuild,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "read-all-packs",
+						Usage: "Read all pack files to generate the new index, ignoring the old one",
+					},
+				},
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, true)
+				},
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runIndexRebuild(c *cli.Context) error {
+	return rebuildIndex(context.Background(), rapiRepo, c.Bool("read-all-packs"))
+}
+
+// rebuildIndex writes a new index for the pack files in the repository. The
+// entries of the old index are reused unless readAllPacks is set, only the
+// packs missing in it or with a different size are read.
+func rebuildIndex(ctx context.Context, repo *repository.Repository, readAllPacks bool) error {
+	var obsoleteIndexes restic.IDs
+	packSizeFromList := make(map[restic.ID]int64)
+	removePacks := restic.NewIDSet()
+
+	if readAllPacks {
+		// get list of old index files but start with empty index
+		err := repo.List(ctx, restic.IndexFile, func(id restic.ID, size int64) error {
+			obsoleteIndexes = append(obsoleteIndexes, id)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		rapi.Printf("loading indexes...\n")
+		err := repo.LoadIndex(ctx)
+		if err != nil {
+			return errors.Fatalf("%v\nuse --read-all-packs to ignore the old index", err)
+		}
+	}
+
+	packSizeFromIndex := repo.Index().PackSize(ctx, false)
+
+	rapi.Printf("getting pack files to read...\n")
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+		size, ok := packSizeFromIndex[id]
+		if !ok || size != packSize {
+			// pack was not referenced in the index or its size does not match
+			packSizeFromList[id] = packSize
+			removePacks.Insert(id)
+		}
+		if !ok {
+			rapi.Warnf("adding pack file to index %v\n", id)
+		} else if size != packSize {
+			rapi.Warnf("reindexing pack file %v with unexpected size %v instead of %v\n", id, packSize, size)
+		}
+		delete(packSizeFromIndex, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for id := range packSizeFromIndex {
+		// forget pack files that are referenced in the index but do not exist
+		removePacks.Insert(id)
+		rapi.Warnf("removing not found pack file %v\n", id)
+	}
+
+	if len(packSizeFromList) > 0 {
+		rapi.Printf("reading %d pack files\n", len(packSizeFromList))
+		invalidFiles, err := repo.CreateIndexFromPacks(ctx, packSizeFromList, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range invalidFiles {
+			rapi.Warnf("skipping invalid pack file %v\n", id)
+		}
+	}
+
+	err = rebuildIndexFiles(ctx, repo, removePacks, obsoleteIndexes)
+	if err != nil {
+		return err
+	}
+
+	rapi.Printf("done\n")
+	return nil
+}
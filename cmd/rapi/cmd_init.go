@@ -0,0 +1,90 @@
+/*
+ * Initialize a new repository, Restic's `init` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_init.go
+ */
+package main
+
+import (
+	"strconv"
+
+	"github.com/restic/chunker"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/backend/location"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "init",
+		Usage:  "Initialize a new repository",
+		Action: runInit,
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "copy-chunker-params",
+				Usage: "Copy the chunker parameters from the repository given with --from-repo",
+			},
+			&cli.StringFlag{
+				Name:  "repository-version",
+				Usage: "Repository format `version` to use: 1, 2, stable or latest",
+				Value: "stable",
+			},
+		}, fromRepoFlags()...),
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// parseRepositoryVersion returns the repository version for the
+// --repository-version value s.
+func parseRepositoryVersion(s string) (uint, error) {
+	switch s {
+	case "stable":
+		return restic.StableRepoVersion, nil
+	case "latest":
+		return restic.MaxRepoVersion, nil
+	}
+
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || v < restic.MinRepoVersion || v > restic.MaxRepoVersion {
+		return 0, errors.Fatalf("unsupported repository version %q, use a version between %d and %d, stable or latest",
+			s, restic.MinRepoVersion, restic.MaxRepoVersion)
+	}
+
+	return uint(v), nil
+}
+
+func runInit(c *cli.Context) error {
+	version, err := parseRepositoryVersion(c.String("repository-version"))
+	if err != nil {
+		return err
+	}
+
+	var pol *chunker.Pol
+	if c.Bool("copy-chunker-params") {
+		srcRepo, err := openFromRepo(c)
+		if err != nil {
+			return err
+		}
+		p := srcRepo.Config().ChunkerPolynomial
+		pol = &p
+	} else if c.String("from-repo") != "" {
+		return errors.Fatal("--from-repo is only used with --copy-chunker-params")
+	}
+
+	repo, err := rapi.InitRepository(globalOptions, version, pol)
+	if err != nil {
+		return err
+	}
+
+	rapi.Printf("created restic repository %v at %s\n", repo.Config().ID[:10], location.StripPassword(repo.Backend().Location()))
+	rapi.Printf("\n")
+	rapi.Printf("Please note that knowledge of your password is required to access\n")
+	rapi.Printf("the repository. Losing your password means that your data is\n")
+	rapi.Printf("irrecoverably lost.\n")
+
+	return nil
+}
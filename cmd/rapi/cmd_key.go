@@ -0,0 +1,252 @@
+/*
+ * Manage the repository keys, Restic's `key` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_key.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/textfile"
+	"github.com/rubiojr/rapi/internal/ui/table"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	newPasswordFlag := &cli.StringFlag{
+		Name:  "new-password-file",
+		Usage: "`file` from which to read the new password",
+	}
+
+	cmd := &cli.Command{
+		Name:  "key",
+		Usage: "Manage keys (passwords)",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the keys of the repository",
+				Action: runKeyList,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the keys as JSON",
+					},
+				},
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, false)
+				},
+			},
+			{
+				Name:   "add",
+				Usage:  "Add a new key (password) to the repository",
+				Action: runKeyAdd,
+				Flags: []cli.Flag{
+					newPasswordFlag,
+					&cli.StringFlag{
+						Name:  "user",
+						Usage: "The `username` for the new key, defaults to the current user",
+					},
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "The `hostname` for the new key, defaults to the current host",
+					},
+				},
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, false)
+				},
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove a key from the repository",
+				ArgsUsage: "<key ID>",
+				Action:    runKeyRemove,
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, true)
+				},
+			},
+			{
+				Name:   "passwd",
+				Usage:  "Change the password of the current key",
+				Action: runKeyPasswd,
+				Flags:  []cli.Flag{newPasswordFlag},
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, true)
+				},
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+type keyInfo struct {
+	Current  bool   `json:"current"`
+	ID       string `json:"id"`
+	UserName string `json:"userName"`
+	HostName string `json:"hostName"`
+	Created  string `json:"created"`
+}
+
+func listKeys(ctx context.Context, repo *repository.Repository) ([]keyInfo, error) {
+	var keys []keyInfo
+
+	err := repo.List(ctx, restic.KeyFile, func(id restic.ID, size int64) error {
+		k, err := repository.LoadKey(ctx, repo, id.String())
+		if err != nil {
+			rapi.Warnf("LoadKey() failed: %v\n", err)
+			return nil
+		}
+
+		keys = append(keys, keyInfo{
+			Current:  id.String() == repo.KeyName(),
+			ID:       id.Str(),
+			UserName: k.Username,
+			HostName: k.Hostname,
+			Created:  k.Created.Local().Format(rapi.TimeFormat),
+		})
+		return nil
+	})
+
+	return keys, err
+}
+
+func runKeyList(c *cli.Context) error {
+	keys, err := listKeys(context.Background(), rapiRepo)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		buf, err := json.MarshalIndent(keys, "", "  ")
+		if err != nil {
+			return err
+		}
+		rapi.Println(string(buf))
+		return nil
+	}
+
+	tab := table.New()
+	tab.AddColumn(" ID", "{{if .Current}}*{{else}} {{end}}{{ .ID }}")
+	tab.AddColumn("User", "{{ .UserName }}")
+	tab.AddColumn("Host", "{{ .HostName }}")
+	tab.AddColumn("Created", "{{ .Created }}")
+
+	for _, key := range keys {
+		tab.AddRow(key)
+	}
+
+	return tab.Write(os.Stdout)
+}
+
+// getNewPassword reads the new password from the file given with
+// --new-password-file or prompts the user for it.
+func getNewPassword(c *cli.Context) (string, error) {
+	if file := c.String("new-password-file"); file != "" {
+		s, err := textfile.Read(file)
+		if os.IsNotExist(errors.Cause(err)) {
+			return "", errors.Fatalf("%s does not exist", file)
+		}
+		if err != nil {
+			return "", errors.Fatalf("unable to read %s: %v", file, err)
+		}
+
+		pw := strings.TrimSpace(string(s))
+		if pw == "" {
+			return "", errors.Fatal("an empty password is not a password")
+		}
+		return pw, nil
+	}
+
+	// Since we already have an open repository, temporary remove the password
+	// to prompt the user for the new one.
+	opts := globalOptions
+	opts.Password = ""
+
+	return rapi.ReadPasswordTwice(opts,
+		"enter new password: ",
+		"enter password again: ")
+}
+
+func runKeyAdd(c *cli.Context) error {
+	pw, err := getNewPassword(c)
+	if err != nil {
+		return err
+	}
+
+	key, err := repository.AddKey(context.Background(), rapiRepo, pw, c.String("user"), c.String("host"), rapiRepo.Key())
+	if err != nil {
+		return errors.Fatalf("creating new key failed: %v\n", err)
+	}
+
+	rapi.Printf("saved new key as %s\n", key.Name())
+	return nil
+}
+
+func runKeyRemove(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() != 1 {
+		return errors.Fatal("key ID not specified")
+	}
+
+	name, err := restic.Find(ctx, rapiRepo.Backend(), restic.KeyFile, c.Args().First())
+	if err != nil {
+		return errors.Fatalf("could not find key %q: %v", c.Args().First(), err)
+	}
+
+	if name == rapiRepo.KeyName() {
+		return errors.Fatal("refusing to remove key currently used to access repository")
+	}
+
+	keys, err := listKeys(ctx, rapiRepo)
+	if err != nil {
+		return err
+	}
+	if len(keys) < 2 {
+		return errors.Fatal("refusing to remove the last key of the repository")
+	}
+
+	h := restic.Handle{Type: restic.KeyFile, Name: name}
+	if err = rapiRepo.Backend().Remove(ctx, h); err != nil {
+		return err
+	}
+
+	rapi.Printf("removed key %v\n", name)
+	return nil
+}
+
+func runKeyPasswd(c *cli.Context) error {
+	ctx := context.Background()
+
+	pw, err := getNewPassword(c)
+	if err != nil {
+		return err
+	}
+
+	// keep the username and hostname of the current key
+	current, err := repository.LoadKey(ctx, rapiRepo, rapiRepo.KeyName())
+	if err != nil {
+		return err
+	}
+
+	key, err := repository.AddKey(ctx, rapiRepo, pw, current.Username, current.Hostname, rapiRepo.Key())
+	if err != nil {
+		return errors.Fatalf("creating new key failed: %v\n", err)
+	}
+
+	h := restic.Handle{Type: restic.KeyFile, Name: rapiRepo.KeyName()}
+	if err = rapiRepo.Backend().Remove(ctx, h); err != nil {
+		return err
+	}
+
+	rapi.Printf("saved new key as %s\n", key.Name())
+	return nil
+}
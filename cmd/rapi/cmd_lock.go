@@ -0,0 +1,109 @@
+/*
+ * Inspect the repository locks.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ */
+package main
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/ui/table"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:  "lock",
+		Usage: "Inspect the repository locks",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List the locks of the repository",
+				Action: runLockList,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print the locks as JSON",
+					},
+				},
+				Before: func(c *cli.Context) error {
+					return setupApp(c)
+				},
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+type lockInfo struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Age       string    `json:"age"`
+	Exclusive bool      `json:"exclusive"`
+	Stale     bool      `json:"stale"`
+	Hostname  string    `json:"hostname"`
+	Username  string    `json:"username"`
+	PID       int       `json:"pid"`
+}
+
+func listLocks(ctx context.Context, repo restic.Repository) ([]lockInfo, error) {
+	locks := []lockInfo{}
+
+	err := restic.ForAllLocks(ctx, repo, nil, func(id restic.ID, lock *restic.Lock, err error) error {
+		if err != nil {
+			rapi.Warnf("unable to load lock %s: %v\n", id.Str(), err)
+			return nil
+		}
+
+		locks = append(locks, lockInfo{
+			ID:        id.Str(),
+			Time:      lock.Time,
+			Age:       time.Since(lock.Time).Round(time.Second).String(),
+			Exclusive: lock.Exclusive,
+			Stale:     lock.Stale(),
+			Hostname:  lock.Hostname,
+			Username:  lock.Username,
+			PID:       lock.PID,
+		})
+		return nil
+	})
+
+	// locks are loaded in parallel, list the oldest ones first
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Time.Before(locks[j].Time)
+	})
+
+	return locks, err
+}
+
+func runLockList(c *cli.Context) error {
+	locks, err := listLocks(context.Background(), rapiRepo)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		return printJSON(locks)
+	}
+
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Holder", "{{ .Username }}")
+	tab.AddColumn("PID", "{{ .PID }}")
+	tab.AddColumn("Host", "{{ .Hostname }}")
+	tab.AddColumn("Age", "{{ .Age }}")
+	tab.AddColumn("Exclusive", "{{ if .Exclusive }}yes{{ end }}")
+	tab.AddColumn("Stale", "{{ if .Stale }}yes{{ end }}")
+
+	for _, lock := range locks {
+		tab.AddRow(lock)
+	}
+
+	return tab.Write(os.Stdout)
+}
@@ -0,0 +1,231 @@
+/*
+ * List the files in a snapshot, Restic's `ls` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_ls.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/fs"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "ls",
+		Usage:     "List files in a snapshot",
+		ArgsUsage: "<snapshot ID|latest> [dir...]",
+		Action:    runLs,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "long",
+				Aliases: []string{"l"},
+				Usage:   "Use a long listing format showing size and mode",
+			},
+			&cli.BoolFlag{
+				Name:  "recursive",
+				Usage: "Include files in subfolders of the listed directories",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the snapshot and the nodes as JSON, one object per line",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+type lsSnapshot struct {
+	*restic.Snapshot
+	ID         *restic.ID `json:"id"`
+	ShortID    string     `json:"short_id"`
+	StructType string     `json:"struct_type"` // "snapshot"
+}
+
+type lsNode struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Path        string      `json:"path"`
+	UID         uint32      `json:"uid"`
+	GID         uint32      `json:"gid"`
+	Size        *uint64     `json:"size,omitempty"`
+	Mode        os.FileMode `json:"mode,omitempty"`
+	Permissions string      `json:"permissions,omitempty"`
+	ModTime     time.Time   `json:"mtime,omitempty"`
+	AccessTime  time.Time   `json:"atime,omitempty"`
+	ChangeTime  time.Time   `json:"ctime,omitempty"`
+	StructType  string      `json:"struct_type"` // "node"
+}
+
+func newLsNode(path string, node *restic.Node) lsNode {
+	n := lsNode{
+		Name:        node.Name,
+		Type:        node.Type,
+		Path:        path,
+		UID:         node.UID,
+		GID:         node.GID,
+		Mode:        node.Mode,
+		Permissions: node.Mode.String(),
+		ModTime:     node.ModTime,
+		AccessTime:  node.AccessTime,
+		ChangeTime:  node.ChangeTime,
+		StructType:  "node",
+	}
+	// Always print size for regular files, even when empty,
+	// but never for other types.
+	if node.Type == "file" {
+		n.Size = &node.Size
+	}
+
+	return n
+}
+
+func runLs(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() == 0 {
+		return errors.Fatal("no snapshot ID specified")
+	}
+
+	// extract any specific directories to walk
+	dirs := c.Args().Slice()[1:]
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir, "/") {
+			return errors.Fatal("all path filters must be absolute, starting with a forward slash '/'")
+		}
+	}
+
+	withinDir := func(nodepath string) bool {
+		if len(dirs) == 0 {
+			return true
+		}
+
+		for _, dir := range dirs {
+			// we're within one of the selected dirs, example:
+			//   nodepath: "/test/foo"
+			//   dir:      "/test"
+			if fs.HasPathPrefix(dir, nodepath) {
+				return true
+			}
+		}
+		return false
+	}
+
+	approachingMatchingTree := func(nodepath string) bool {
+		if len(dirs) == 0 {
+			return true
+		}
+
+		for _, dir := range dirs {
+			// the current node path is a prefix for one of the
+			// directories, so we're interested in something deeper in the
+			// tree. Example:
+			//   nodepath: "/test"
+			//   dir:      "/test/foo"
+			if fs.HasPathPrefix(nodepath, dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	id, err := findSnapshot(ctx, rapiRepo, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, rapiRepo, id)
+	if err != nil {
+		return errors.Fatalf("loading snapshot %q failed: %v", id.Str(), err)
+	}
+
+	long := c.Bool("long")
+	recursive := c.Bool("recursive")
+
+	var printSnapshot func(sn *restic.Snapshot) error
+	var printNode func(path string, node *restic.Node) error
+
+	if c.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+
+		printSnapshot = func(sn *restic.Snapshot) error {
+			return enc.Encode(lsSnapshot{
+				Snapshot:   sn,
+				ID:         sn.ID(),
+				ShortID:    sn.ID().Str(),
+				StructType: "snapshot",
+			})
+		}
+
+		printNode = func(path string, node *restic.Node) error {
+			return enc.Encode(newLsNode(path, node))
+		}
+	} else {
+		printSnapshot = func(sn *restic.Snapshot) error {
+			rapi.Printf("snapshot %s of %v at %s:\n", sn.ID().Str(), sn.Paths, sn.Time.Local().Format(rapi.TimeFormat))
+			return nil
+		}
+
+		printNode = func(path string, node *restic.Node) error {
+			rapi.Printf("%s\n", formatNode(path, node, long))
+			return nil
+		}
+	}
+
+	if err = printSnapshot(sn); err != nil {
+		return err
+	}
+
+	return walker.Walk(ctx, rapiRepo, *sn.Tree, nil, func(_ restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			return false, err
+		}
+		if node == nil {
+			return false, nil
+		}
+
+		if withinDir(nodepath) {
+			// if we're within a dir, print the node
+			if err := printNode(nodepath, node); err != nil {
+				return false, err
+			}
+
+			// if recursive listing is requested, signal the walker that it
+			// should continue walking recursively
+			if recursive {
+				return false, nil
+			}
+		}
+
+		// if there's an upcoming match deeper in the tree (but we're not
+		// there yet), signal the walker to descend into any subdirs
+		if approachingMatchingTree(nodepath) {
+			return false, nil
+		}
+
+		// otherwise, signal the walker to not walk recursively into any
+		// subdirs
+		if node.Type == "dir" {
+			return false, walker.ErrSkipNode
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,116 @@
+/*
+ * Apply migrations to the repository, Restic's `migrate` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_migrate.go
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/migrations"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "migrate",
+		Usage:     "Apply migrations",
+		ArgsUsage: "[name]",
+		Action:    runMigrate,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "force",
+				Aliases: []string{"f"},
+				Usage:   "Apply a migration even if the check suggests it's not necessary",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, true)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runMigrate(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() == 0 {
+		return checkMigrations(ctx)
+	}
+
+	return applyMigrations(ctx, c.Bool("force"), c.Args().Slice())
+}
+
+func checkMigrations(ctx context.Context) error {
+	rapi.Printf("available migrations:\n")
+	found := false
+
+	for _, m := range migrations.All {
+		ok, err := m.Check(ctx, rapiRepo)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			rapi.Printf("  %v\t%v\n", m.Name(), m.Desc())
+			found = true
+		}
+	}
+
+	if !found {
+		rapi.Printf("no migrations found\n")
+	}
+
+	return nil
+}
+
+func applyMigrations(ctx context.Context, force bool, names []string) error {
+	var firsterr error
+	for _, name := range names {
+		found := false
+		for _, m := range migrations.All {
+			if m.Name() != name {
+				continue
+			}
+			found = true
+
+			ok, err := m.Check(ctx, rapiRepo)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				if !force {
+					rapi.Warnf("migration %v cannot be applied: check failed\nIf you want to apply this migration anyway, re-run with option --force\n", m.Name())
+					continue
+				}
+
+				rapi.Warnf("check for migration %v failed, continuing anyway\n", m.Name())
+			}
+
+			rapi.Printf("applying migration %v...\n", m.Name())
+			if err = m.Apply(ctx, rapiRepo); err != nil {
+				rapi.Warnf("migration %v failed: %v\n", m.Name(), err)
+				if firsterr == nil {
+					firsterr = err
+				}
+				continue
+			}
+
+			rapi.Printf("migration %v: success\n", m.Name())
+		}
+
+		if !found {
+			rapi.Warnf("unknown migration %v\n", name)
+			if firsterr == nil {
+				firsterr = errors.Fatalf("unknown migration %v", name)
+			}
+		}
+	}
+
+	return firsterr
+}
@@ -0,0 +1,150 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+/*
+ * Mount a repository with FUSE, Restic's `mount` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_mount.go
+ */
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	resticfs "github.com/rubiojr/rapi/internal/fs"
+	"github.com/rubiojr/rapi/internal/fuse"
+	"github.com/rubiojr/rapi/internal/ui/signals"
+	"github.com/urfave/cli/v2"
+
+	systemFuse "bazil.org/fuse"
+	"bazil.org/fuse/fs"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "mount",
+		Usage:     "Mount the repository",
+		ArgsUsage: "<mountpoint>",
+		Action:    runMount,
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "allow-other",
+				Usage: "Allow other users to access the data in the mounted directory",
+			},
+			&cli.BoolFlag{
+				Name:  "no-default-permissions",
+				Usage: "For 'allow-other', ignore Unix permissions and allow users to read all snapshot files",
+			},
+			&cli.BoolFlag{
+				Name:  "owner-root",
+				Usage: "Use 'root' as the owner of files and dirs",
+			},
+			&cli.StringFlag{
+				Name:  "snapshot-template",
+				Usage: "Set `template` to use for snapshot dirs",
+				Value: time.RFC3339,
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			if err := setupApp(c); err != nil {
+				return err
+			}
+			// runMount unmounts when interrupted and returns, the lock is
+			// released after it
+			return lockRepoWithoutInterrupt(context.Background(), rapiRepo, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runMount(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() != 1 {
+		return errors.Fatal("wrong number of parameters")
+	}
+
+	tmpl := c.String("snapshot-template")
+	if tmpl == "" {
+		return errors.Fatal("snapshot template string cannot be empty")
+	}
+	if strings.ContainsAny(tmpl, `\/`) {
+		return errors.Fatal("snapshot template string contains a slash (/) or backslash (\\) character")
+	}
+
+	mountpoint := c.Args().First()
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	if _, err := resticfs.Stat(mountpoint); os.IsNotExist(errors.Cause(err)) {
+		rapi.Printf("Mountpoint %s doesn't exist, creating it\n", mountpoint)
+		err = resticfs.Mkdir(mountpoint, os.ModeDir|0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	mountOptions := []systemFuse.MountOption{
+		systemFuse.ReadOnly(),
+		systemFuse.FSName("rapi"),
+		systemFuse.MaxReadahead(128 * 1024),
+	}
+
+	if c.Bool("allow-other") {
+		mountOptions = append(mountOptions, systemFuse.AllowOther())
+
+		// let the kernel check permissions unless it is explicitly disabled
+		if !c.Bool("no-default-permissions") {
+			mountOptions = append(mountOptions, systemFuse.DefaultPermissions())
+		}
+	}
+
+	conn, err := systemFuse.Mount(mountpoint, mountOptions...)
+	if err != nil {
+		return err
+	}
+
+	systemFuse.Debug = func(msg interface{}) {
+		debug.Log("fuse: %v", msg)
+	}
+
+	// unmount on SIGINT/SIGTERM, this makes fs.Serve return
+	go func() {
+		<-signals.GetInterruptChannel()
+		debug.Log("unmounting %v", mountpoint)
+		if err := systemFuse.Unmount(mountpoint); err != nil {
+			rapi.Warnf("unable to umount (maybe already umounted or still in use?): %v\n", err)
+		}
+	}()
+
+	hosts, tags, paths := snapshotFilter(c)
+	cfg := fuse.Config{
+		OwnerIsRoot:      c.Bool("owner-root"),
+		Hosts:            hosts,
+		Tags:             tags,
+		Paths:            paths,
+		SnapshotTemplate: tmpl,
+	}
+	root := fuse.NewRoot(rapiRepo, cfg)
+
+	rapi.Printf("Now serving the repository at %s\n", mountpoint)
+	rapi.Printf("When finished, quit with Ctrl-c or umount the mountpoint.\n")
+
+	debug.Log("serving mount at %v", mountpoint)
+	err = fs.Serve(conn, root)
+	if err != nil {
+		return err
+	}
+
+	<-conn.Ready
+	return conn.MountError
+}
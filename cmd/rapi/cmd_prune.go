@@ -0,0 +1,578 @@
+/*
+ * Remove unneeded data from the repository, Restic's `prune` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_prune.go
+ */
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+var errorIndexIncomplete = errors.Fatal("index is not complete")
+var errorPacksMissing = errors.Fatal("packs from index missing in repo")
+var errorSizeNotMatching = errors.Fatal("pack size does not match calculated size from index")
+
+func init() {
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "Do not modify the repository, just print what would be done",
+		},
+	}
+	flags = append(flags, pruneFlags()...)
+
+	cmd := &cli.Command{
+		Name:   "prune",
+		Usage:  "Remove unneeded data from the repository",
+		Action: runPrune,
+		Flags:  flags,
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, true)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// pruneFlags returns the flags shared by the prune and forget commands.
+func pruneFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "max-unused",
+			Usage: "Tolerate given `limit` of unused data (absolute value in bytes with suffixes k/K, m/M, g/G, t/T, a value in % or the word 'unlimited')",
+			Value: "5%",
+		},
+		&cli.StringFlag{
+			Name:  "max-repack-size",
+			Usage: "Maximum `size` to repack (allowed suffixes: k/K, m/M, g/G, t/T)",
+		},
+		&cli.BoolFlag{
+			Name:  "repack-cacheable-only",
+			Usage: "Only repack packs which are cacheable",
+		},
+	}
+}
+
+// pruneOptions collects all options for the prune command.
+type pruneOptions struct {
+	DryRun              bool
+	MaxRepackBytes      uint64
+	RepackCacheableOnly bool
+
+	maxUnusedBytes func(used uint64) uint64 // calculates the number of unused bytes after repacking, according to MaxUnused
+}
+
+// parsePruneOptions reads the prune options from the flags returned by
+// pruneFlags and the dry-run flag.
+func parsePruneOptions(c *cli.Context) (pruneOptions, error) {
+	opts := pruneOptions{
+		DryRun:              c.Bool("dry-run"),
+		RepackCacheableOnly: c.Bool("repack-cacheable-only"),
+	}
+
+	if s := c.String("max-repack-size"); s != "" {
+		size, err := humanize.ParseBytes(s)
+		if err != nil {
+			return opts, errors.Fatalf("invalid size %q for --max-repack-size: %v", s, err)
+		}
+		opts.MaxRepackBytes = size
+	}
+
+	maxUnused := strings.TrimSpace(c.String("max-unused"))
+	if maxUnused == "" {
+		return opts, errors.Fatalf("invalid value for --max-unused: %q", c.String("max-unused"))
+	}
+
+	// parse MaxUnused either as unlimited, a percentage, or an absolute number of bytes
+	switch {
+	case maxUnused == "unlimited":
+		opts.maxUnusedBytes = func(used uint64) uint64 {
+			return math.MaxUint64
+		}
+
+	case strings.HasSuffix(maxUnused, "%"):
+		p, err := strconv.ParseFloat(strings.TrimSuffix(maxUnused, "%"), 64)
+		if err != nil {
+			return opts, errors.Fatalf("invalid percentage %q passed for --max-unused: %v", maxUnused, err)
+		}
+
+		if p < 0 {
+			return opts, errors.Fatal("percentage for --max-unused must be positive")
+		}
+
+		if p >= 100 {
+			return opts, errors.Fatal("percentage for --max-unused must be below 100%")
+		}
+
+		opts.maxUnusedBytes = func(used uint64) uint64 {
+			return uint64(p / (100 - p) * float64(used))
+		}
+
+	default:
+		size, err := humanize.ParseBytes(maxUnused)
+		if err != nil {
+			return opts, errors.Fatalf("invalid number of bytes %q for --max-unused: %v", maxUnused, err)
+		}
+
+		opts.maxUnusedBytes = func(used uint64) uint64 {
+			return size
+		}
+	}
+
+	return opts, nil
+}
+
+func runPrune(c *cli.Context) error {
+	opts, err := parsePruneOptions(c)
+	if err != nil {
+		return err
+	}
+
+	return runPruneWithRepo(context.Background(), rapiRepo, opts)
+}
+
+func runPruneWithRepo(ctx context.Context, repo *repository.Repository, opts pruneOptions) error {
+	// we do not need index updates while pruning!
+	repo.DisableAutoIndexUpdate()
+
+	rapi.Printf("loading indexes...\n")
+	err := repo.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	usedBlobs, err := getUsedBlobs(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	return prune(ctx, repo, opts, usedBlobs)
+}
+
+type packInfo struct {
+	usedBlobs      uint
+	unusedBlobs    uint
+	duplicateBlobs uint
+	usedSize       uint64
+	unusedSize     uint64
+	tpe            restic.BlobType
+}
+
+type packInfoWithID struct {
+	ID restic.ID
+	packInfo
+}
+
+// prune selects which files to rewrite and then does that. The number of
+// used blobs is reduced to zero during the process.
+func prune(ctx context.Context, repo *repository.Repository, opts pruneOptions, usedBlobs restic.BlobSet) error {
+	var stats struct {
+		blobs struct {
+			used      uint
+			duplicate uint
+			unused    uint
+			remove    uint
+			repack    uint
+			repackrm  uint
+		}
+		size struct {
+			used      uint64
+			duplicate uint64
+			unused    uint64
+			remove    uint64
+			repack    uint64
+			repackrm  uint64
+			unref     uint64
+		}
+		packs struct {
+			used       uint
+			unused     uint
+			partlyUsed uint
+			keep       uint
+		}
+	}
+
+	rapi.Printf("searching used packs...\n")
+
+	keepBlobs := restic.NewBlobSet()
+	duplicateBlobs := restic.NewBlobSet()
+
+	// iterate over all blobs in index to find out which blobs are duplicates
+	for blob := range repo.Index().Each(ctx) {
+		bh := blob.BlobHandle
+		size := uint64(blob.Length)
+		switch {
+		case usedBlobs.Has(bh): // used blob, move to keepBlobs
+			usedBlobs.Delete(bh)
+			keepBlobs.Insert(bh)
+			stats.size.used += size
+			stats.blobs.used++
+		case keepBlobs.Has(bh): // duplicate blob
+			duplicateBlobs.Insert(bh)
+			stats.size.duplicate += size
+			stats.blobs.duplicate++
+		default:
+			stats.size.unused += size
+			stats.blobs.unused++
+		}
+	}
+
+	// Check if all used blobs have been found in index
+	if len(usedBlobs) != 0 {
+		rapi.Warnf("%v not found in the index\n\n"+
+			"Integrity check failed: Data seems to be missing.\n"+
+			"Will not start prune to prevent (additional) data loss!\n", usedBlobs)
+		return errorIndexIncomplete
+	}
+
+	indexPack := make(map[restic.ID]packInfo)
+
+	// save computed pack header size
+	for pid, hdrSize := range repo.Index().PackSize(ctx, true) {
+		// initialize tpe with NumBlobTypes to indicate it's not set
+		indexPack[pid] = packInfo{tpe: restic.NumBlobTypes, usedSize: uint64(hdrSize)}
+	}
+
+	// iterate over all blobs in index to generate packInfo
+	for blob := range repo.Index().Each(ctx) {
+		ip := indexPack[blob.PackID]
+
+		// Set blob type if not yet set
+		if ip.tpe == restic.NumBlobTypes {
+			ip.tpe = blob.Type
+		}
+
+		// mark mixed packs with "Invalid blob type"
+		if ip.tpe != blob.Type {
+			ip.tpe = restic.InvalidBlob
+		}
+
+		bh := blob.BlobHandle
+		size := uint64(blob.Length)
+		switch {
+		case duplicateBlobs.Has(bh): // duplicate blob
+			ip.usedSize += size
+			ip.duplicateBlobs++
+		case keepBlobs.Has(bh): // used blob, not duplicate
+			ip.usedSize += size
+			ip.usedBlobs++
+		default: // unused blob
+			ip.unusedSize += size
+			ip.unusedBlobs++
+		}
+		// update indexPack
+		indexPack[blob.PackID] = ip
+	}
+
+	rapi.Printf("collecting packs for deletion and repacking\n")
+	removePacksFirst := restic.NewIDSet()
+	removePacks := restic.NewIDSet()
+	repackPacks := restic.NewIDSet()
+
+	var repackCandidates []packInfoWithID
+	repackAllPacksWithDuplicates := true
+
+	keep := func(p packInfo) {
+		stats.packs.keep++
+		if p.duplicateBlobs > 0 {
+			repackAllPacksWithDuplicates = false
+		}
+	}
+
+	// loop over all packs and decide what to do
+	err := repo.List(ctx, restic.PackFile, func(id restic.ID, packSize int64) error {
+		p, ok := indexPack[id]
+		if !ok {
+			// Pack was not referenced in index and is not used  => immediately remove!
+			removePacksFirst.Insert(id)
+			stats.size.unref += uint64(packSize)
+			return nil
+		}
+
+		if p.unusedSize+p.usedSize != uint64(packSize) &&
+			!(p.usedBlobs == 0 && p.duplicateBlobs == 0) {
+			// Pack size does not fit and pack is needed => error
+			// If the pack is not needed, this is no error, the pack can
+			// and will be simply removed, see below.
+			rapi.Warnf("pack %s: calculated size %d does not match real size %d\n",
+				id.Str(), p.unusedSize+p.usedSize, packSize)
+			return errorSizeNotMatching
+		}
+
+		// statistics
+		switch {
+		case p.usedBlobs == 0 && p.duplicateBlobs == 0:
+			stats.packs.unused++
+		case p.unusedBlobs == 0:
+			stats.packs.used++
+		default:
+			stats.packs.partlyUsed++
+		}
+
+		// decide what to do
+		switch {
+		case p.usedBlobs == 0 && p.duplicateBlobs == 0:
+			// All blobs in pack are no longer used => remove pack!
+			removePacks.Insert(id)
+			stats.blobs.remove += p.unusedBlobs
+			stats.size.remove += p.unusedSize
+
+		case opts.RepackCacheableOnly && p.tpe == restic.DataBlob:
+			// if this is a data pack and --repack-cacheable-only is set => keep pack!
+			keep(p)
+
+		case p.unusedBlobs == 0 && p.duplicateBlobs == 0 && p.tpe != restic.InvalidBlob:
+			// All blobs in pack are used and not duplicates/mixed => keep pack!
+			keep(p)
+
+		default:
+			// all other packs are candidates for repacking
+			repackCandidates = append(repackCandidates, packInfoWithID{ID: id, packInfo: p})
+		}
+
+		delete(indexPack, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// At this point indexPacks contains only missing packs!
+
+	// missing packs that are not needed can be ignored
+	ignorePacks := restic.NewIDSet()
+	for id, p := range indexPack {
+		if p.usedBlobs == 0 && p.duplicateBlobs == 0 {
+			ignorePacks.Insert(id)
+			stats.blobs.remove += p.unusedBlobs
+			stats.size.remove += p.unusedSize
+			delete(indexPack, id)
+		}
+	}
+
+	if len(indexPack) != 0 {
+		rapi.Warnf("The index references %d needed pack files which are missing from the repository:\n", len(indexPack))
+		for id := range indexPack {
+			rapi.Warnf("  %v\n", id)
+		}
+		return errorPacksMissing
+	}
+	if len(ignorePacks) != 0 {
+		rapi.Warnf("Missing but unneeded pack files are referenced in the index, will be repaired\n")
+		for id := range ignorePacks {
+			rapi.Warnf("will forget missing pack file %v\n", id)
+		}
+	}
+
+	// calculate limit for number of unused bytes in the repo after repacking
+	maxUnusedSizeAfter := opts.maxUnusedBytes(stats.size.used)
+
+	// Sort repackCandidates such that packs with highest ratio unused/used space are picked first.
+	// This is equivalent to sorting by unused / total space.
+	// Instead of unused[i] / used[i] > unused[j] / used[j] we use
+	// unused[i] * used[j] > unused[j] * used[i] as uint32*uint32 < uint64
+	// Moreover duplicates and packs containing trees are sorted to the beginning
+	sort.Slice(repackCandidates, func(i, j int) bool {
+		pi := repackCandidates[i].packInfo
+		pj := repackCandidates[j].packInfo
+		switch {
+		case pi.duplicateBlobs > 0 && pj.duplicateBlobs == 0:
+			return true
+		case pj.duplicateBlobs > 0 && pi.duplicateBlobs == 0:
+			return false
+		case pi.tpe != restic.DataBlob && pj.tpe == restic.DataBlob:
+			return true
+		case pj.tpe != restic.DataBlob && pi.tpe == restic.DataBlob:
+			return false
+		}
+		return pi.unusedSize*pj.usedSize > pj.unusedSize*pi.usedSize
+	})
+
+	repack := func(id restic.ID, p packInfo) {
+		repackPacks.Insert(id)
+		stats.blobs.repack += p.unusedBlobs + p.duplicateBlobs + p.usedBlobs
+		stats.size.repack += p.unusedSize + p.usedSize
+		stats.blobs.repackrm += p.unusedBlobs
+		stats.size.repackrm += p.unusedSize
+	}
+
+	for _, p := range repackCandidates {
+		reachedUnusedSizeAfter := (stats.size.unused-stats.size.remove-stats.size.repackrm < maxUnusedSizeAfter)
+
+		reachedRepackSize := false
+		if opts.MaxRepackBytes > 0 {
+			reachedRepackSize = stats.size.repack+p.unusedSize+p.usedSize > opts.MaxRepackBytes
+		}
+
+		switch {
+		case reachedRepackSize:
+			keep(p.packInfo)
+
+		case p.duplicateBlobs > 0, p.tpe != restic.DataBlob:
+			// repacking duplicates/non-data is only limited by repackSize
+			repack(p.ID, p.packInfo)
+
+		case reachedUnusedSizeAfter:
+			// for all other packs stop repacking if tolerated unused size is reached.
+			keep(p.packInfo)
+
+		default:
+			repack(p.ID, p.packInfo)
+		}
+	}
+
+	// if all duplicates are repacked, print out correct statistics
+	if repackAllPacksWithDuplicates {
+		stats.blobs.repackrm += stats.blobs.duplicate
+		stats.size.repackrm += stats.size.duplicate
+	}
+
+	totalBlobs := stats.blobs.used + stats.blobs.unused + stats.blobs.duplicate
+	totalSize := stats.size.used + stats.size.duplicate + stats.size.unused
+	unusedAfter := stats.size.unused - stats.size.remove - stats.size.repackrm
+	remainingSize := totalSize - (stats.size.remove + stats.size.repackrm)
+
+	rapi.Printf("\n")
+	rapi.Printf("used:         %10d blobs / %s\n", stats.blobs.used, humanize.Bytes(stats.size.used))
+	if stats.blobs.duplicate > 0 {
+		rapi.Printf("duplicates:   %10d blobs / %s\n", stats.blobs.duplicate, humanize.Bytes(stats.size.duplicate))
+	}
+	rapi.Printf("unused:       %10d blobs / %s\n", stats.blobs.unused, humanize.Bytes(stats.size.unused))
+	if stats.size.unref > 0 {
+		rapi.Printf("unreferenced:                    %s\n", humanize.Bytes(stats.size.unref))
+	}
+	rapi.Printf("total:        %10d blobs / %s\n", totalBlobs, humanize.Bytes(totalSize+stats.size.unref))
+	rapi.Printf("unused size: %s of total size\n", formatPercent(stats.size.unused, totalSize))
+
+	rapi.Printf("\n")
+	rapi.Printf("to repack:    %10d blobs / %s\n", stats.blobs.repack, humanize.Bytes(stats.size.repack))
+	rapi.Printf("this removes  %10d blobs / %s\n", stats.blobs.repackrm, humanize.Bytes(stats.size.repackrm))
+	rapi.Printf("to delete:    %10d blobs / %s\n", stats.blobs.remove, humanize.Bytes(stats.size.remove+stats.size.unref))
+	rapi.Printf("total prune:  %10d blobs / %s\n", stats.blobs.remove+stats.blobs.repackrm, humanize.Bytes(stats.size.remove+stats.size.repackrm+stats.size.unref))
+	rapi.Printf("remaining:    %10d blobs / %s\n", totalBlobs-(stats.blobs.remove+stats.blobs.repackrm), humanize.Bytes(remainingSize))
+	rapi.Printf("unused size after prune: %s (%s of remaining size)\n",
+		humanize.Bytes(unusedAfter), formatPercent(unusedAfter, remainingSize))
+
+	rapi.Printf("\n")
+	rapi.Printf("totally used packs: %10d\n", stats.packs.used)
+	rapi.Printf("partly used packs:  %10d\n", stats.packs.partlyUsed)
+	rapi.Printf("unused packs:       %10d\n", stats.packs.unused)
+	rapi.Printf("to keep:            %10d packs\n", stats.packs.keep)
+	rapi.Printf("to repack:          %10d packs\n", len(repackPacks))
+	rapi.Printf("to delete:          %10d packs\n", len(removePacks))
+	if len(removePacksFirst) > 0 {
+		rapi.Printf("to delete:          %10d unreferenced packs\n", len(removePacksFirst))
+	}
+	rapi.Printf("\n")
+
+	if opts.DryRun {
+		rapi.Printf("dry run, the repository was not modified\n")
+		return nil
+	}
+
+	// unreferenced packs can be safely deleted first
+	if len(removePacksFirst) != 0 {
+		rapi.Printf("deleting unreferenced packs\n")
+		deleteFiles(ctx, repo, removePacksFirst, restic.PackFile)
+	}
+
+	if len(repackPacks) != 0 {
+		rapi.Printf("repacking packs\n")
+		_, err := repository.Repack(ctx, repo, repackPacks, keepBlobs, nil)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+
+		// Also remove repacked packs
+		removePacks.Merge(repackPacks)
+	}
+
+	ignorePacks.Merge(removePacks)
+
+	if len(ignorePacks) != 0 {
+		err = rebuildIndexFiles(ctx, repo, ignorePacks, nil)
+		if err != nil {
+			return errors.Fatalf("%s", err)
+		}
+	}
+
+	if len(removePacks) != 0 {
+		rapi.Printf("removing %d old packs\n", len(removePacks))
+		deleteFiles(ctx, repo, removePacks, restic.PackFile)
+	}
+
+	rapi.Printf("done\n")
+	return nil
+}
+
+// rebuildIndexFiles writes a new index without the packs in removePacks and
+// removes the index files it replaces.
+func rebuildIndexFiles(ctx context.Context, repo *repository.Repository, removePacks restic.IDSet, extraObsolete restic.IDs) error {
+	rapi.Printf("rebuilding index\n")
+
+	idx := (repo.Index()).(*repository.MasterIndex)
+	obsoleteIndexes, err := idx.Save(ctx, repo, removePacks, extraObsolete, nil)
+	if err != nil {
+		return err
+	}
+
+	rapi.Printf("deleting obsolete index files\n")
+	return deleteFilesChecked(ctx, repo, obsoleteIndexes, restic.IndexFile, false)
+}
+
+// getUsedBlobs returns the blobs referenced by the trees of all snapshots.
+func getUsedBlobs(ctx context.Context, repo restic.Repository) (usedBlobs restic.BlobSet, err error) {
+	var snapshotTrees restic.IDs
+	rapi.Printf("loading all snapshots...\n")
+	err = restic.ForAllSnapshots(ctx, repo, nil,
+		func(id restic.ID, sn *restic.Snapshot, err error) error {
+			if err != nil {
+				return err
+			}
+			snapshotTrees = append(snapshotTrees, *sn.Tree)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	rapi.Printf("finding data that is still in use for %d snapshots\n", len(snapshotTrees))
+
+	usedBlobs = restic.NewBlobSet()
+	err = restic.FindUsedBlobs(ctx, repo, snapshotTrees, usedBlobs, nil)
+	if err != nil {
+		if repo.Backend().IsNotExist(err) {
+			return nil, errors.Fatal("unable to load a tree from the repo: " + err.Error())
+		}
+
+		return nil, err
+	}
+	return usedBlobs, nil
+}
+
+func formatPercent(numerator uint64, denominator uint64) string {
+	if denominator == 0 {
+		return ""
+	}
+
+	percent := 100.0 * float64(numerator) / float64(denominator)
+	if percent > 100 {
+		percent = 100
+	}
+
+	return strconv.FormatFloat(percent, 'f', 3, 64) + "%"
+}
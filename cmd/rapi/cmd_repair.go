@@ -0,0 +1,165 @@
+/*
+ * Repair the repository after data was lost, like Restic's `repair` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:  "repair",
+		Usage: "Repair the repository",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "snapshots",
+				Usage:     "Remove the references to missing data from snapshots",
+				ArgsUsage: "[snapshot ID...]",
+				Action:    repairSnapshots,
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "forget",
+						Usage: "Remove the damaged snapshots once the repaired ones are saved",
+					},
+				}, snapshotFilterFlags()...),
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, true)
+				},
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// rewrittenSnapshot is a snapshot and the root of its rewritten tree.
+type rewrittenSnapshot struct {
+	sn   *restic.Snapshot
+	tree restic.ID
+}
+
+func repairSnapshots(c *cli.Context) error {
+	ctx := context.Background()
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	snapshots, err := findSnapshots(ctx, rapiRepo, c)
+	if err != nil {
+		return err
+	}
+
+	rw := walker.NewTreeRewriter(func(node *restic.Node, path string) *restic.Node {
+		if node.Type != "file" {
+			return node
+		}
+		return repairFile(rapiRepo, node, path)
+	})
+	rw.FailedTree = func(id restic.ID, path string, err error) error {
+		rapi.Printf("  dir %q: tree %v cannot be loaded, removed\n", path, id.Str())
+		return nil
+	}
+
+	var repaired []rewrittenSnapshot
+	lost := 0
+	for _, sn := range snapshots {
+		rapi.Printf("snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time.Local().Format(rapi.TimeFormat))
+
+		if sn.Tree == nil {
+			rapi.Warnf("  snapshot has no tree, it cannot be repaired\n")
+			lost++
+			continue
+		}
+
+		// without its root tree there is nothing left to keep
+		if _, err := rapiRepo.LoadTree(ctx, *sn.Tree); err != nil {
+			rapi.Warnf("  root tree %v cannot be loaded, the snapshot cannot be repaired: %v\n", sn.Tree.Str(), err)
+			lost++
+			continue
+		}
+
+		tree, err := rw.RewriteTree(ctx, rapiRepo, "/", *sn.Tree)
+		if err != nil {
+			return errors.Fatalf("unable to repair snapshot %s: %v", sn.ID().Str(), err)
+		}
+		if tree.Equal(*sn.Tree) {
+			rapi.Printf("  no damage found\n")
+			continue
+		}
+		repaired = append(repaired, rewrittenSnapshot{sn: sn, tree: tree})
+	}
+
+	// the repaired trees must be stored before the snapshots use them
+	if err := rapiRepo.Flush(ctx); err != nil {
+		return err
+	}
+
+	for _, r := range repaired {
+		oldID := r.sn.ID()
+		sn := *r.sn
+		sn.Tree = &r.tree
+		sn.AddTags([]string{"repaired"})
+
+		var id restic.ID
+		if c.Bool("forget") {
+			id, err = restic.ReplaceSnapshot(ctx, rapiRepo, &sn)
+		} else {
+			if sn.Original == nil {
+				sn.Original = oldID
+			}
+			id, err = rapiRepo.SaveJSONUnpacked(ctx, restic.SnapshotFile, &sn)
+		}
+		if err != nil {
+			return errors.Fatalf("unable to save the repaired snapshot %s: %v", oldID.Str(), err)
+		}
+		rapi.Printf("snapshot %s repaired as %s\n", oldID.Str(), id.Str())
+	}
+
+	switch {
+	case len(repaired) == 0:
+		rapi.Println("no snapshots were modified")
+	case c.Bool("forget"):
+		rapi.Printf("repaired %d snapshots, the damaged snapshots were removed\n", len(repaired))
+	default:
+		rapi.Printf("repaired %d snapshots, remove the damaged snapshots with `rapi forget` or use --forget\n", len(repaired))
+	}
+
+	if lost > 0 {
+		return errors.Fatalf("%d snapshots could not be repaired", lost)
+	}
+
+	return nil
+}
+
+// repairFile returns node with its content truncated before the first blob
+// missing from the index, or nil if nothing is left.
+func repairFile(repo restic.Repository, node *restic.Node, path string) *restic.Node {
+	var size uint64
+	for i, id := range node.Content {
+		blobSize, found := repo.LookupBlobSize(id, restic.DataBlob)
+		if !found {
+			if i == 0 {
+				rapi.Printf("  file %q: blob %v is missing, removed\n", path, id.Str())
+				return nil
+			}
+
+			rapi.Printf("  file %q: blob %v is missing, truncated to %d bytes\n", path, id.Str(), size)
+			n := *node
+			n.Content = append(restic.IDs{}, node.Content[:i]...)
+			n.Size = size
+			return &n
+		}
+		size += uint64(blobSize)
+	}
+
+	return node
+}
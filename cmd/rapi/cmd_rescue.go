@@ -26,7 +26,39 @@ func init() {
 					},
 				},
 				Before: func(c *cli.Context) error {
-					return setupApp(c)
+					return setupAppLocked(c, false)
+				},
+			},
+			&cli.Command{
+				Usage:  "Recover the trees not referenced by any snapshot into a new snapshot",
+				Name:   "recover",
+				Action: rescueRecover,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "`hostname` of the new snapshot, defaults to the current host",
+					},
+				},
+				Before: func(c *cli.Context) error {
+					return setupAppLocked(c, false)
+				},
+			},
+			&cli.Command{
+				Usage:     "Extract files and raw chunks from the pack files of a damaged repository",
+				Name:      "carve",
+				ArgsUsage: "<data directory>",
+				Action:    rescueCarve,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "key-file",
+						Usage:    "Master key `file` printed by `rapi cat masterkey`, or a key file from the keys directory",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "target",
+						Usage:    "Directory where the files will be written",
+						Required: true,
+					},
 				},
 			},
 		},
@@ -0,0 +1,427 @@
+/*
+ * Extract files and raw chunks from pack files, without the index, the
+ * snapshots or the repository config.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/crypto"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/pack"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+// carvedBlob is a blob found in the header of a pack file.
+type carvedBlob struct {
+	pack string
+	blob restic.Blob
+}
+
+// carver extracts the data of the pack files in a directory.
+type carver struct {
+	key *crypto.Key
+
+	// blobs maps every blob to the pack files containing it
+	blobs map[restic.BlobHandle][]carvedBlob
+	trees map[restic.ID]*restic.Tree
+
+	// usedData are the data blobs referenced by a file written
+	usedData restic.IDSet
+
+	packs, invalidPacks     int
+	files, incompleteFiles  int
+	missingTrees, rawChunks int
+	skippedNodes, rootTrees int
+}
+
+func rescueCarve(c *cli.Context) error {
+	dataDir := c.Args().First()
+	if dataDir == "" {
+		return errors.Fatal("data directory not specified")
+	}
+	target := c.String("target")
+
+	key, err := loadCarveKey(c.String("key-file"))
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(target, 0700); err != nil {
+		return err
+	}
+
+	cv := &carver{
+		key:      key,
+		blobs:    make(map[restic.BlobHandle][]carvedBlob),
+		trees:    make(map[restic.ID]*restic.Tree),
+		usedData: restic.NewIDSet(),
+	}
+
+	rapi.Printf("reading pack headers in %s\n", dataDir)
+	if err = cv.readPacks(dataDir); err != nil {
+		return err
+	}
+	rapi.Printf("found %d blobs in %d packs, %d packs could not be read\n", len(cv.blobs), cv.packs, cv.invalidPacks)
+
+	rapi.Printf("loading trees\n")
+	cv.loadTrees()
+
+	roots := cv.findRoots()
+	cv.rootTrees = len(roots)
+	rapi.Printf("writing the files of %d root trees\n", len(roots))
+	for _, id := range roots {
+		if err = cv.writeTree(id, filepath.Join(target, id.Str())); err != nil {
+			return err
+		}
+	}
+
+	if err = cv.writeChunks(filepath.Join(target, "chunks")); err != nil {
+		return err
+	}
+
+	rapi.Printf("\n")
+	rapi.Printf("     Root trees:   %d\n", cv.rootTrees)
+	rapi.Printf("  Missing trees:   %d\n", cv.missingTrees)
+	rapi.Printf("  Files written:   %d\n", cv.files)
+	rapi.Printf("     Incomplete:   %d\n", cv.incompleteFiles)
+	rapi.Printf("     Raw chunks:   %d\n", cv.rawChunks)
+	rapi.Printf("  Skipped items:   %d\n", cv.skippedNodes)
+
+	return nil
+}
+
+// loadCarveKey loads the master key printed by `rapi cat masterkey` or,
+// asking for the password, the master key in a repository key file.
+func loadCarveKey(file string) (*crypto.Key, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Fatalf("unable to read key file: %v", err)
+	}
+
+	master := &crypto.Key{}
+	if err := json.Unmarshal(buf, master); err == nil && master.Valid() {
+		return master, nil
+	}
+
+	pw, err := rapi.ReadPassword(globalOptions, "enter password for the key file: ")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := repository.OpenKeyFromFile(file, pw)
+	if err != nil {
+		return nil, errors.Fatalf("unable to open key file %s: %v", file, err)
+	}
+
+	return key.Master(), nil
+}
+
+// readPacks lists the blobs in the headers of all the files below dir.
+func (cv *carver) readPacks(dir string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			rapi.Warnf("unable to read %s: %v\n", path, err)
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			rapi.Warnf("unable to open %s: %v\n", path, err)
+			cv.invalidPacks++
+			return nil
+		}
+		defer f.Close()
+
+		entries, _, err := pack.List(cv.key, f, fi.Size())
+		if err != nil {
+			rapi.Warnf("unable to read the header of %s: %v\n", path, err)
+			cv.invalidPacks++
+			return nil
+		}
+
+		for _, blob := range entries {
+			h := blob.BlobHandle
+			cv.blobs[h] = append(cv.blobs[h], carvedBlob{pack: path, blob: blob})
+		}
+		cv.packs++
+
+		return nil
+	})
+}
+
+// loadBlob returns the plaintext of the blob, trying all the packs which
+// contain it.
+func (cv *carver) loadBlob(h restic.BlobHandle) ([]byte, error) {
+	copies, ok := cv.blobs[h]
+	if !ok {
+		return nil, errors.Errorf("%v not found", h)
+	}
+
+	var err error
+	for _, cb := range copies {
+		var buf []byte
+		buf, err = cv.loadCopy(cb)
+		if err == nil {
+			return buf, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (cv *carver) loadCopy(cb carvedBlob) ([]byte, error) {
+	f, err := os.Open(cb.pack)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return cb.blob.DecryptAndCheck(f, cv.key, true)
+}
+
+// loadTrees decodes all the tree blobs which can be loaded.
+func (cv *carver) loadTrees() {
+	for h := range cv.blobs {
+		if h.Type != restic.TreeBlob {
+			continue
+		}
+
+		buf, err := cv.loadBlob(h)
+		if err != nil {
+			rapi.Warnf("unable to load tree %v: %v\n", h.ID.Str(), err)
+			continue
+		}
+
+		tree := &restic.Tree{}
+		if err = json.Unmarshal(buf, tree); err != nil {
+			rapi.Warnf("unable to decode tree %v: %v\n", h.ID.Str(), err)
+			continue
+		}
+		cv.trees[h.ID] = tree
+	}
+}
+
+// findRoots returns the trees not referenced by other trees, sorted by ID.
+// Without the snapshots, these are the snapshot trees and the trees whose
+// parents were lost.
+func (cv *carver) findRoots() restic.IDs {
+	referenced := restic.NewIDSet()
+	for _, tree := range cv.trees {
+		for _, node := range tree.Nodes {
+			if node.Type == "dir" && node.Subtree != nil {
+				referenced.Insert(*node.Subtree)
+			}
+		}
+	}
+
+	var roots restic.IDs
+	for id := range cv.trees {
+		if !referenced.Has(id) {
+			roots = append(roots, id)
+		}
+	}
+	sort.Sort(roots)
+
+	return roots
+}
+
+// validName reports whether name can be used as a file name in the target
+// directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// writeTree writes the contents of the tree to dir.
+func (cv *carver) writeTree(id restic.ID, dir string) error {
+	tree, ok := cv.trees[id]
+	if !ok {
+		rapi.Warnf("tree %v for %s is missing\n", id.Str(), dir)
+		cv.missingTrees++
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for _, node := range tree.Nodes {
+		if !validName(node.Name) {
+			rapi.Warnf("skipping invalid name %q in %s\n", node.Name, dir)
+			cv.skippedNodes++
+			continue
+		}
+		path := filepath.Join(dir, node.Name)
+
+		switch node.Type {
+		case "dir":
+			if node.Subtree == nil {
+				continue
+			}
+			if err := cv.writeTree(*node.Subtree, path); err != nil {
+				return err
+			}
+		case "file":
+			if err := cv.writeFile(node, path); err != nil {
+				return err
+			}
+		case "symlink":
+			if err := os.Symlink(node.LinkTarget, path); err != nil {
+				rapi.Warnf("unable to create symlink %s: %v\n", path, err)
+			}
+		default:
+			cv.skippedNodes++
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the blobs of the file found in the packs to path. The
+// size of a blob missing from all the pack headers is not known, so the
+// pieces of the file around such blobs are written to numbered parts,
+// path.part1, path.part2 and so on.
+func (cv *carver) writeFile(node *restic.Node, path string) error {
+	var pieces []restic.IDs
+	var piece restic.IDs
+	missing := 0
+	for _, id := range node.Content {
+		if _, ok := cv.blobs[restic.BlobHandle{ID: id, Type: restic.DataBlob}]; ok {
+			piece = append(piece, id)
+			continue
+		}
+
+		missing++
+		if len(piece) > 0 {
+			pieces = append(pieces, piece)
+			piece = nil
+		}
+	}
+	if len(piece) > 0 || missing == 0 {
+		pieces = append(pieces, piece)
+	}
+
+	filled := 0
+	for i, ids := range pieces {
+		name := path
+		if missing > 0 {
+			name = fmt.Sprintf("%s.part%d", path, i+1)
+		}
+
+		n, err := cv.writePiece(node, ids, name)
+		if err != nil {
+			return err
+		}
+		filled += n
+	}
+
+	if missing == 0 && filled == 0 {
+		cv.files++
+		return nil
+	}
+
+	cv.incompleteFiles++
+	if missing > 0 {
+		rapi.Warnf("%s is incomplete, %d of %d blobs are missing, the rest was written to %d parts\n", path, missing, len(node.Content), len(pieces))
+	}
+	if filled > 0 {
+		rapi.Warnf("%s is incomplete, %d of %d blobs could not be loaded and were filled with zeros\n", path, filled, len(node.Content))
+	}
+
+	return nil
+}
+
+// writePiece writes the blobs to path. The blobs which cannot be loaded are
+// replaced by zeros, so that the following data stays at its offset. It
+// returns the number of blobs filled with zeros.
+func (cv *carver) writePiece(node *restic.Node, ids restic.IDs, path string) (int, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	filled := 0
+	for _, id := range ids {
+		h := restic.BlobHandle{ID: id, Type: restic.DataBlob}
+		buf, err := cv.loadBlob(h)
+		if err != nil {
+			rapi.Warnf("unable to load blob %v of %s, filled with zeros: %v\n", id.Str(), path, err)
+			buf = make([]byte, cv.blobs[h][0].blob.DataLength())
+			filled++
+		}
+		cv.usedData.Insert(id)
+
+		if _, err = f.Write(buf); err != nil {
+			_ = f.Close()
+			return filled, err
+		}
+	}
+
+	if err = f.Close(); err != nil {
+		return filled, err
+	}
+
+	// the contents are what matters, metadata errors are not fatal
+	_ = os.Chmod(path, node.Mode.Perm())
+	_ = os.Chtimes(path, node.AccessTime, node.ModTime)
+
+	return filled, nil
+}
+
+// writeChunks writes the data blobs not written to any file to dir,
+// numbered in the order they are stored in the packs so that consecutive
+// chunks of a file are likely to have consecutive numbers.
+func (cv *carver) writeChunks(dir string) error {
+	var chunks []carvedBlob
+	for h, copies := range cv.blobs {
+		if h.Type == restic.DataBlob && !cv.usedData.Has(h.ID) {
+			chunks = append(chunks, copies[0])
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].pack != chunks[j].pack {
+			return chunks[i].pack < chunks[j].pack
+		}
+		return chunks[i].blob.Offset < chunks[j].blob.Offset
+	})
+
+	rapi.Printf("writing %d data blobs not referenced by any tree\n", len(chunks))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for i, cb := range chunks {
+		buf, err := cv.loadBlob(cb.blob.BlobHandle)
+		if err != nil {
+			rapi.Warnf("unable to load blob %v: %v\n", cb.blob.ID.Str(), err)
+			continue
+		}
+
+		name := filepath.Join(dir, fmt.Sprintf("%06d-%s", i+1, cb.blob.ID))
+		if err = ioutil.WriteFile(name, buf, 0600); err != nil {
+			return err
+		}
+		cv.rawChunks++
+	}
+
+	return nil
+}
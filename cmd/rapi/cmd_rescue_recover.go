@@ -0,0 +1,140 @@
+/*
+ * Recover the trees of lost snapshots, Restic's `recover` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_recover.go
+ */
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func rescueRecover(c *cli.Context) error {
+	ctx := context.Background()
+
+	hostname := c.String("host")
+	if hostname == "" {
+		var err error
+		hostname, err = os.Hostname()
+		if err != nil {
+			return err
+		}
+	}
+
+	rapi.Printf("load index files\n")
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	roots, err := findRootTrees(ctx, rapiRepo)
+	if err != nil {
+		return err
+	}
+
+	rapi.Printf("found %d unreferenced roots\n", len(roots))
+	if len(roots) == 0 {
+		rapi.Printf("no snapshot to write\n")
+		return nil
+	}
+
+	// every root tree becomes a directory named after the tree ID
+	now := time.Now()
+	tree := restic.NewTree(len(roots))
+	for id := range roots {
+		subtreeID := id
+		node := restic.Node{
+			Type:       "dir",
+			Name:       id.Str(),
+			Mode:       os.ModeDir | 0755,
+			Subtree:    &subtreeID,
+			AccessTime: now,
+			ModTime:    now,
+			ChangeTime: now,
+		}
+		if err := tree.Insert(&node); err != nil {
+			return err
+		}
+	}
+
+	treeID, err := rapiRepo.SaveTree(ctx, tree)
+	if err != nil {
+		return err
+	}
+	if err = rapiRepo.Flush(ctx); err != nil {
+		return err
+	}
+
+	sn, err := restic.NewSnapshot([]string{"/recover"}, []string{"recovered"}, hostname, now)
+	if err != nil {
+		return err
+	}
+	sn.Tree = &treeID
+
+	id, err := rapiRepo.SaveJSONUnpacked(ctx, restic.SnapshotFile, sn)
+	if err != nil {
+		return err
+	}
+
+	rapi.Printf("saved new snapshot %v\n", id.Str())
+	return nil
+}
+
+// findRootTrees returns the trees in the index not referenced by any other
+// tree or snapshot.
+func findRootTrees(ctx context.Context, repo *repository.Repository) (restic.IDSet, error) {
+	// trees maps a tree ID to whether it is referenced by a tree or snapshot
+	trees := make(map[restic.ID]bool)
+	for blob := range repo.Index().Each(ctx) {
+		if blob.Type == restic.TreeBlob {
+			trees[blob.Blob.ID] = false
+		}
+	}
+
+	rapi.Printf("load %d trees\n", len(trees))
+	for id := range trees {
+		tree, err := repo.LoadTree(ctx, id)
+		if err != nil {
+			rapi.Warnf("unable to load tree %v: %v\n", id.Str(), err)
+			continue
+		}
+
+		for _, node := range tree.Nodes {
+			if node.Type == "dir" && node.Subtree != nil {
+				trees[*node.Subtree] = true
+			}
+		}
+	}
+
+	rapi.Printf("load snapshots\n")
+	err := restic.ForAllSnapshots(ctx, repo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
+		if err != nil {
+			rapi.Warnf("unable to load snapshot %v: %v\n", id.Str(), err)
+			return nil
+		}
+		if sn.Tree != nil {
+			trees[*sn.Tree] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roots := restic.NewIDSet()
+	for id, referenced := range trees {
+		if !referenced {
+			roots.Insert(id)
+		}
+	}
+
+	return roots, nil
+}
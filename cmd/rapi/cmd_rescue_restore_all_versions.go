@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/restic"
 	"github.com/rubiojr/rapi/walker"
 	"github.com/urfave/cli/v2"
@@ -45,6 +47,7 @@ func restoreAllVersions(c *cli.Context) error {
 	}
 
 	filesFound := map[fileID]bool{}
+	damagedFiles := 0
 	restic.ForAllSnapshots(ctx, rapiRepo, nil, func(id restic.ID, sn *restic.Snapshot, err error) error {
 		if err != nil {
 			return err
@@ -53,15 +56,22 @@ func restoreAllVersions(c *cli.Context) error {
 		if sn.Tree == nil {
 			return fmt.Errorf("snapshot %s has nil tree", sn.ID().Str())
 		}
-		return walker.Walk(ctx, rapiRepo, *sn.Tree, restic.NewIDSet(), rescueWalkTree(pattern, filesFound, targetDir))
+		return walker.Walk(ctx, rapiRepo, *sn.Tree, restic.NewIDSet(), rescueWalkTree(pattern, filesFound, &damagedFiles, targetDir))
 	})
 
 	s.Stop()
 	fmt.Printf("%d files matched.\n", len(filesFound))
+	if damagedFiles > 0 {
+		return errors.Fatalf("%d of the restored files are damaged", damagedFiles)
+	}
 	return nil
 }
 
-func restoreFile(fid fileID, name string, blobIDs restic.IDs, targetDir string) error {
+// restoreFile writes the blobs to a file in targetDir. Damaged blobs are
+// replaced by zeros, the file ends before the first blob missing from the
+// index since the position of the following blobs is not known. It returns
+// whether the file is damaged.
+func restoreFile(fid fileID, name string, blobIDs restic.IDs, targetDir string) (bool, error) {
 	hash := fmt.Sprintf("%x", fid)
 	fname := hash[:8] + "_" + name
 	if len(fname) >= 254 {
@@ -70,23 +80,44 @@ func restoreFile(fid fileID, name string, blobIDs restic.IDs, targetDir string)
 	dest := filepath.Join(targetDir, fname)
 
 	f, err := os.Create(dest)
-	defer f.Close()
 	if err != nil {
-		panic(err)
+		return false, err
 	}
+	defer f.Close()
 
+	damaged := false
+	offset := 0
 	for _, rid := range blobIDs {
 		buf, err := rapiRepo.LoadBlob(context.Background(), restic.DataBlob, rid, nil)
 		if err != nil {
-			return err
+			damaged = true
+			size, found := rapiRepo.LookupBlobSize(rid, restic.DataBlob)
+			if !found {
+				rescueWarnf("%s: blob %v not found in the index, truncated to %d bytes\n", dest, rid.Str(), offset)
+				break
+			}
+
+			rescueWarnf("%s: bytes %d-%d filled with zeros: %v\n", dest, offset, offset+int(size), err)
+			buf = make([]byte, size)
+		}
+
+		if _, err := f.Write(buf); err != nil {
+			return damaged, err
 		}
-		f.Write(buf)
+		offset += len(buf)
 	}
 
-	return nil
+	return damaged, f.Close()
 }
 
-func rescueWalkTree(pattern string, filesFound map[fileID]bool, targetDir string) walker.WalkFunc {
+// rescueWarnf prints a warning without mixing it with the spinner.
+func rescueWarnf(format string, args ...interface{}) {
+	s.Stop()
+	rapi.Warnf(format, args...)
+	s.Start()
+}
+
+func rescueWalkTree(pattern string, filesFound map[fileID]bool, damagedFiles *int, targetDir string) walker.WalkFunc {
 	return func(parentTreeID restic.ID, npath string, node *restic.Node, nodeErr error) (bool, error) {
 		if nodeErr != nil {
 			return true, nodeErr
@@ -103,7 +134,10 @@ func rescueWalkTree(pattern string, filesFound map[fileID]bool, targetDir string
 
 		if ok, _ := filepath.Match(pattern, node.Name); ok {
 			filesFound[fid] = true
-			err := restoreFile(fid, node.Name, node.Content, targetDir)
+			damaged, err := restoreFile(fid, node.Name, node.Content, targetDir)
+			if damaged {
+				*damagedFiles++
+			}
 			if err != nil {
 				s.Suffix = fmt.Sprintf(" error %s", node.Name)
 			} else {
@@ -0,0 +1,226 @@
+/*
+ * Restore a snapshot, Restic's `restore` command with progress reporting.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_restore.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/dustin/go-humanize"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/restorer"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "restore",
+		Usage:     "Extract the data from a snapshot",
+		ArgsUsage: "<snapshot ID|latest>",
+		Action:    runRestore,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "target",
+				Aliases:  []string{"t"},
+				Usage:    "Directory to extract data to",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:    "include",
+				Aliases: []string{"i"},
+				Usage:   "Restore only items matching `pattern` (can be specified multiple times)",
+			},
+			&cli.StringSliceFlag{
+				Name:    "exclude",
+				Aliases: []string{"e"},
+				Usage:   "Do not restore items matching `pattern` (can be specified multiple times)",
+			},
+			&cli.BoolFlag{
+				Name:  "verify",
+				Usage: "Verify restored files content",
+			},
+			&cli.BoolFlag{
+				Name:  "allow-damage",
+				Usage: "Fill missing or damaged data with zeros and report the damaged ranges",
+			},
+			&cli.StringFlag{
+				Name:  "damage-report",
+				Usage: "Write the JSON damage report of --allow-damage to `file` instead of stderr",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runRestore(c *cli.Context) error {
+	ctx := context.Background()
+	if c.NArg() != 1 {
+		return errors.Fatal("no snapshot ID specified")
+	}
+	if c.IsSet("damage-report") && !c.Bool("allow-damage") {
+		return errors.Fatal("--damage-report requires --allow-damage")
+	}
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	id, err := findSnapshot(ctx, rapiRepo, c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	res, err := restorer.NewRestorer(ctx, rapiRepo, id)
+	if err != nil {
+		return errors.Fatalf("creating restorer failed: %v\n", err)
+	}
+
+	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
+	s.Color("fgHiRed")
+
+	var (
+		totalErrors                    uint64
+		totalBytes, restoredBytes      uint64
+		totalFiles, restoredItemsCount uint64
+	)
+	res.Error = func(location string, err error) error {
+		atomic.AddUint64(&totalErrors, 1)
+		s.Stop()
+		rapi.Warnf("ignoring error for %s: %s\n", location, err)
+		s.Start()
+		return nil
+	}
+	var (
+		damageLock sync.Mutex
+		damage     = []restorer.Damage{}
+	)
+	if c.Bool("allow-damage") {
+		res.Damaged = func(d restorer.Damage) {
+			damageLock.Lock()
+			damage = append(damage, d)
+			damageLock.Unlock()
+		}
+	}
+	res.SelectFilter = restorer.SelectByPatterns(c.StringSlice("include"), c.StringSlice("exclude"))
+	res.Progress = restorer.Progress{
+		AddFile: func(location string, size uint64) {
+			atomic.AddUint64(&totalFiles, 1)
+			atomic.AddUint64(&totalBytes, size)
+		},
+		CompleteBlob: func(location string, bytes uint64) {
+			atomic.AddUint64(&restoredBytes, bytes)
+		},
+		CompleteItem: func(location string, node *restic.Node) {
+			atomic.AddUint64(&restoredItemsCount, 1)
+		},
+	}
+
+	target := c.String("target")
+	s.Suffix = fmt.Sprintf(" Restoring snapshot %s to %s", res.Snapshot().ID().Str(), target)
+	s.Start()
+
+	// the callbacks run concurrently in the restore workers, the progress
+	// is only shown by this goroutine
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			done := atomic.LoadUint64(&restoredBytes)
+			if done == 0 {
+				continue
+			}
+			s.Lock()
+			s.Suffix = fmt.Sprintf(" Restoring %d files: %s / %s", atomic.LoadUint64(&totalFiles),
+				humanize.Bytes(done), humanize.Bytes(atomic.LoadUint64(&totalBytes)))
+			s.Unlock()
+		}
+	}()
+
+	err = res.RestoreTo(ctx, target)
+	close(stop)
+	wg.Wait()
+	s.Stop()
+	if err != nil {
+		return err
+	}
+
+	printRow("Restored items", fmt.Sprintf("%d", atomic.LoadUint64(&restoredItemsCount)), headerColor)
+	printRow("Restored size", humanize.Bytes(atomic.LoadUint64(&restoredBytes)), headerColor)
+
+	if c.Bool("verify") {
+		s.Suffix = " Verifying restored files"
+		s.Start()
+		count, err := res.VerifyFiles(ctx, target)
+		s.Stop()
+		if err != nil {
+			return err
+		}
+		printRow("Verified files", fmt.Sprintf("%d", count), headerColor)
+	}
+
+	if c.Bool("allow-damage") {
+		sort.Slice(damage, func(i, j int) bool {
+			if damage[i].Location != damage[j].Location {
+				return damage[i].Location < damage[j].Location
+			}
+			return damage[i].Start < damage[j].Start
+		})
+		if err := writeDamageReport(c.String("damage-report"), damage); err != nil {
+			return errors.Fatalf("unable to write the damage report: %v", err)
+		}
+	}
+
+	if len(damage) > 0 {
+		return errors.Fatalf("%d ranges of the restored files are damaged\n", len(damage))
+	}
+
+	if n := atomic.LoadUint64(&totalErrors); n > 0 {
+		return errors.Fatalf("There were %d errors\n", n)
+	}
+
+	return nil
+}
+
+// writeDamageReport writes the JSON report of the damaged ranges to the file
+// path, or to stderr if path is empty, to keep it apart from the summary.
+func writeDamageReport(path string, damage []restorer.Damage) error {
+	buf, err := json.MarshalIndent(damage, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+
+	if path == "" {
+		_, err = os.Stderr.Write(buf)
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0600)
+}
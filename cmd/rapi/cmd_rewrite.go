@@ -0,0 +1,154 @@
+/*
+ * Remove files from existing snapshots, like Restic's `rewrite` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/filter"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "rewrite",
+		Usage:     "Remove files from existing snapshots",
+		ArgsUsage: "[snapshot ID...]",
+		Action:    runRewrite,
+		Flags: append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "exclude",
+				Aliases: []string{"e"},
+				Usage:   "Remove the items matching `pattern` (can be specified multiple times)",
+			},
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "Rewrite all the snapshots matching the filters, instead of the ones given",
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Aliases: []string{"n"},
+				Usage:   "Only list the items which would be removed",
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			// a dry run only reads the repository
+			return setupAppLocked(c, !c.Bool("dry-run"))
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// dryRunTreeSaver returns the IDs of the trees without saving them.
+type dryRunTreeSaver struct {
+	walker.TreeLoader
+}
+
+func (dryRunTreeSaver) SaveTree(ctx context.Context, tree *restic.Tree) (restic.ID, error) {
+	// the same encoding as Repository.SaveTree
+	buf, err := json.Marshal(tree)
+	if err != nil {
+		return restic.ID{}, err
+	}
+	buf = append(buf, '\n')
+	return restic.Hash(buf), nil
+}
+
+func runRewrite(c *cli.Context) error {
+	ctx := context.Background()
+
+	excludes := filter.ParsePatterns(c.StringSlice("exclude"))
+	if len(excludes) == 0 {
+		return errors.Fatal("nothing to do, use --exclude")
+	}
+	if c.Bool("all") == (c.NArg() > 0) {
+		return errors.Fatal("either --all or the snapshot IDs must be given")
+	}
+	dryRun := c.Bool("dry-run")
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	snapshots, err := findSnapshots(ctx, rapiRepo, c)
+	if err != nil {
+		return err
+	}
+
+	var repo walker.TreeLoadSaver = rapiRepo
+	if dryRun {
+		repo = dryRunTreeSaver{rapiRepo}
+	}
+
+	var rewritten []rewrittenSnapshot
+	for _, sn := range snapshots {
+		rapi.Printf("snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time.Local().Format(rapi.TimeFormat))
+
+		if sn.Tree == nil {
+			rapi.Warnf("  snapshot has no tree, skipped\n")
+			continue
+		}
+
+		rw := walker.NewTreeRewriter(func(node *restic.Node, path string) *restic.Node {
+			matched, err := filter.List(excludes, path)
+			if err != nil {
+				debug.Log("error for exclude pattern: %v", err)
+			}
+			if !matched {
+				return node
+			}
+
+			if dryRun {
+				rapi.Printf("  would remove %s\n", path)
+			} else {
+				rapi.Printf("  removing %s\n", path)
+			}
+			return nil
+		})
+
+		tree, err := rw.RewriteTree(ctx, repo, "/", *sn.Tree)
+		if err != nil {
+			return errors.Fatalf("unable to rewrite snapshot %s: %v", sn.ID().Str(), err)
+		}
+		if !tree.Equal(*sn.Tree) {
+			rewritten = append(rewritten, rewrittenSnapshot{sn: sn, tree: tree})
+		}
+	}
+
+	if dryRun {
+		rapi.Printf("would rewrite %d snapshots\n", len(rewritten))
+		return nil
+	}
+
+	// the new trees must be stored before the snapshots use them
+	if err := rapiRepo.Flush(ctx); err != nil {
+		return err
+	}
+
+	for _, r := range rewritten {
+		oldID := r.sn.ID()
+		r.sn.Tree = &r.tree
+		id, err := restic.ReplaceSnapshot(ctx, rapiRepo, r.sn)
+		if err != nil {
+			return errors.Fatalf("unable to save the rewritten snapshot %s: %v", oldID.Str(), err)
+		}
+		rapi.Printf("snapshot %s saved as %s\n", oldID.Str(), id.Str())
+	}
+
+	if len(rewritten) == 0 {
+		rapi.Println("no snapshots were modified")
+	} else {
+		rapi.Printf("rewrote %d snapshots, run `rapi prune` to remove the data no longer referenced\n", len(rewritten))
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Serve repositories using restic's REST protocol, like rest-server.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ */
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/backend"
+	"github.com/rubiojr/rapi/backend/restserver"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/ui/signals"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "serve",
+		Usage:  "Serve a repository, or the repositories in a directory, over the REST protocol",
+		Action: runServe,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "path",
+				Usage: "Data `directory` with the repositories, instead of the repository given with -r",
+			},
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "Listen `address`",
+				Value: ":8000",
+			},
+			&cli.BoolFlag{
+				Name:  "append-only",
+				Usage: "Only allow adding data, except removing locks",
+			},
+			&cli.BoolFlag{
+				Name:  "private-repos",
+				Usage: "Users can only access the repositories below a directory named like them, requires --path",
+			},
+			&cli.StringFlag{
+				Name:  "htpasswd-file",
+				Usage: "htpasswd `file` with the users, defaults to .htpasswd in the data directory",
+			},
+			&cli.BoolFlag{
+				Name:  "no-auth",
+				Usage: "Disable authentication",
+			},
+			&cli.BoolFlag{
+				Name:  "prometheus",
+				Usage: "Export Prometheus metrics at /metrics",
+			},
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runServe(c *cli.Context) error {
+	path := c.String("path")
+
+	cfg := restserver.Config{
+		AppendOnly:   c.Bool("append-only"),
+		PrivateRepos: c.Bool("private-repos"),
+		Metrics:      c.Bool("prometheus"),
+		Logf:         rapi.Warnf,
+	}
+
+	if cfg.PrivateRepos && path == "" {
+		return errors.Fatal("--private-repos requires --path")
+	}
+
+	if !c.Bool("no-auth") {
+		file := c.String("htpasswd-file")
+		if file == "" && path == "" {
+			return errors.Fatal("please specify the users with --htpasswd-file, use --no-auth to disable authentication")
+		}
+		if file == "" {
+			file = filepath.Join(path, ".htpasswd")
+		}
+
+		htpasswd, err := restserver.LoadHtpasswd(file)
+		if err != nil {
+			return errors.Fatalf("unable to load %s, use --no-auth to disable authentication: %v", file, err)
+		}
+		cfg.Htpasswd = htpasswd
+	}
+
+	if cfg.PrivateRepos && cfg.Htpasswd == nil {
+		return errors.Fatal("--private-repos requires authentication")
+	}
+
+	var backends restserver.Backends
+	var location string
+	if path != "" {
+		if err := os.MkdirAll(path, backend.Modes.Dir); err != nil {
+			return errors.Fatalf("unable to create the data directory: %v", err)
+		}
+		backends = restserver.NewLocalBackends(path)
+		location = "repositories in " + path
+	} else {
+		// any repository rapi can open is served as the one at the root
+		be, err := rapi.OpenBackend(globalOptions)
+		if err != nil {
+			return err
+		}
+		defer be.Close()
+		backends = restserver.NewSingleBackend(be)
+		location = "repository " + be.Location()
+	}
+
+	handler, err := restserver.New(backends, cfg)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: c.String("listen"), Handler: handler}
+
+	go func() {
+		<-signals.GetInterruptChannel()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	rapi.Printf("serving %s on %s\n", location, srv.Addr)
+	err = srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
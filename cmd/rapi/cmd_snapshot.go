@@ -19,7 +19,7 @@ func init() {
 				Action: printSnapshotInfo,
 				Flags:  []cli.Flag{},
 				Before: func(c *cli.Context) error {
-					return setupApp(c)
+					return setupAppLocked(c, false)
 				},
 			},
 		},
@@ -27,13 +27,6 @@ func printSnapshotInfo(c *cli.Context) error {
 		return err
 	}
 
-	// create a container for the stats (and other needed state)
-	stats := &statsContainer{
-		uniqueFiles:  make(map[fileID]struct{}),
-		uniqueInodes: make(map[uint64]struct{}),
-		blobs:        restic.NewBlobSet(),
-	}
-
 	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
 	s.Color("fgHiRed")
 	s.Suffix = " Calculating snapshot stats, this may take some time"
@@ -48,63 +41,47 @@ func printSnapshotInfo(c *cli.Context) error {
 		return err
 	}
 
-	err = statsWalkSnapshot(ctx, sn, rapiRepo, stats)
+	info, err := walkSnapshotInfo(ctx, rapiRepo, *sn.Tree)
 	if err != nil {
-		return fmt.Errorf("error walking snapshot: %v", err)
-	}
-
-	// the blob handles have been collected, but not yet counted
-	for blobHandle := range stats.blobs {
-		blobSize, found := rapiRepo.LookupBlobSize(blobHandle.ID, blobHandle.Type)
-		if !found {
-			return fmt.Errorf("blob %v not found", blobHandle)
-		}
-		stats.TotalBlobSize += uint64(blobSize)
-		stats.TotalBlobCount++
+		return err
 	}
 
-	//FIXME: TotalBlobSize is greater than RestoreSize when there are no duplicated files in the
-	// repo. The encryption overhead??
+	// compare the restore size to the size of the file contents only, the
+	// trees are not restored
 	var dedupedSize uint64
-	if stats.RestoreSize < stats.TotalBlobSize {
-		dedupedSize = 0
-	} else {
-		dedupedSize = stats.RestoreSize - stats.TotalBlobSize
+	if info.size > info.dataSize {
+		dedupedSize = info.size - info.dataSize
 	}
 
 	s.Stop()
-	printRow("Total Blob Count", fmt.Sprintf("%d", stats.TotalBlobCount), headerColor)
+	printRow("Total Blob Count", fmt.Sprintf("%d", info.blobCount), headerColor)
 	printRow(
 		"Unique Files Size",
-		humanize.Bytes(stats.TotalBlobSize)+fmt.Sprintf(" (deduped %s)", humanize.Bytes(dedupedSize)),
+		humanize.Bytes(info.dataSize)+fmt.Sprintf(" (deduped %s)", humanize.Bytes(dedupedSize)),
 		headerColor,
 	)
-	printRow("Total Files", fmt.Sprintf("%d", stats.TotalFileCount), headerColor)
-	printRow("Unique Files", fmt.Sprintf("%d", stats.UniqueFileCount), headerColor)
-	printRow("Restore Size", humanize.Bytes(stats.RestoreSize), headerColor)
+	printRow("Total Files", fmt.Sprintf("%d", info.totalFiles), headerColor)
+	printRow("Unique Files", fmt.Sprintf("%d", info.uniqueFiles), headerColor)
+	printRow("Restore Size", humanize.Bytes(info.size), headerColor)
 
 	return nil
 }
 
-func statsWalkSnapshot(ctx context.Context, snapshot *restic.Snapshot, repo restic.Repository, stats *statsContainer) error {
-	if snapshot.Tree == nil {
-		return fmt.Errorf("snapshot %s has nil tree", snapshot.ID().Str())
-	}
-
-	// count just the sizes of unique blobs; we don't need to walk the tree
-	// ourselves in this case, since a nifty function does it for us
-	restic.FindUsedBlobs(ctx, repo, restic.IDs{*snapshot.Tree}, stats.blobs, nil)
-
-	err := walker.Walk(ctx, repo, *snapshot.Tree, restic.NewIDSet(), statsWalkTree(repo, stats))
-	if err != nil {
-		return fmt.Errorf("walking tree %s: %v", *snapshot.Tree, err)
-	}
-
-	return nil
+// snapshotInfo holds the counters printed by `snapshot info`.
+type snapshotInfo struct {
+	blobCount, dataSize     uint64
+	totalFiles, uniqueFiles uint64
+	size                    uint64
 }
 
-func statsWalkTree(repo restic.Repository, stats *statsContainer) walker.WalkFunc {
-	return func(parentTreeID restic.ID, npath string, node *restic.Node, nodeErr error) (bool, error) {
+// walkSnapshotInfo collects all the counters of the snapshot tree in a single
+// walk.
+func walkSnapshotInfo(ctx context.Context, repo restic.Repository, tree restic.ID) (snapshotInfo, error) {
+	var info snapshotInfo
+	hardlinks := make(map[hardlinkKey]struct{})
+	uniqueFiles := make(map[fileID]struct{})
+	blobs := restic.NewBlobSet(restic.BlobHandle{ID: tree, Type: restic.TreeBlob})
+	err := walker.Walk(ctx, repo, tree, restic.NewIDSet(), func(_ restic.ID, _ string, node *restic.Node, nodeErr error) (bool, error) {
 		if nodeErr != nil {
 			return true, nodeErr
 		}
@@ -112,59 +89,36 @@ func statsWalkTree(repo restic.Repository, stats *statsContainer) walker.WalkFun
 			return true, nil
 		}
 
-		// only count this file if we haven't visited it before
-		fid := makeFileIDByContents(node)
-		if _, ok := stats.uniqueFiles[fid]; !ok {
-			// mark the file as visited
-			stats.uniqueFiles[fid] = struct{}{}
-
-			// simply count the size of each unique file (unique by contents only)
-			stats.TotalSize += node.Size
-			stats.UniqueFileCount++
+		info.size += restoreSize(node, hardlinks)
+		switch node.Type {
+		case "file":
+			info.totalFiles++
+			uniqueFiles[makeFileIDByContents(node)] = struct{}{}
+			for _, id := range node.Content {
+				blobs.Insert(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+			}
+		case "dir":
+			blobs.Insert(restic.BlobHandle{ID: *node.Subtree, Type: restic.TreeBlob})
 		}
 
-		if node.Type == "file" {
-			stats.TotalFileCount++
-		}
+		// identical trees at other paths are restored too, walk them again
+		return false, nil
+	})
+	if err != nil {
+		return snapshotInfo{}, fmt.Errorf("error walking snapshot: %v", err)
+	}
+	info.uniqueFiles = uint64(len(uniqueFiles))
 
-		// if inodes are present, only count each inode once
-		// (hard links do not increase restore size)
-		if _, ok := stats.uniqueInodes[node.Inode]; !ok || node.Inode == 0 {
-			stats.uniqueInodes[node.Inode] = struct{}{}
-			stats.RestoreSize += node.Size
+	for h := range blobs {
+		blobSize, found := repo.LookupBlobSize(h.ID, h.Type)
+		if !found {
+			return snapshotInfo{}, fmt.Errorf("blob %v not found", h)
+		}
+		info.blobCount++
+		if h.Type == restic.DataBlob {
+			info.dataSize += uint64(blobSize)
 		}
-
-		return true, nil
 	}
-}
 
-// statsContainer holds information during a walk of a repository
-// to collect information about it, as well as state needed
-// for a successful and efficient walk.
-type statsContainer struct {
-	TotalSize       uint64
-	RestoreSize     uint64
-	TotalFileCount  uint64
-	UniqueFileCount uint64
-	TotalBlobCount  uint64
-	TotalBlobSize   uint64
-
-	// uniqueFiles marks visited files according to their
-	// contents (hashed sequence of content blob IDs)
-	uniqueFiles map[fileID]struct{}
-
-	// uniqueInodes marks visited files according to their
-	// inode # (hashed sequence of inode numbers)
-	uniqueInodes map[uint64]struct{}
-
-	// blobs is used to count individual unique blobs,
-	// independent of references to files
-	blobs restic.BlobSet
+	return info, nil
 }
-
-const (
-	countModeRestoreSize           = "restore-size"
-	countModeUniqueFilesByContents = "files-by-contents"
-	countModeBlobsPerFile          = "blobs-per-file"
-	countModeRawData               = "raw-data"
-)
@@ -0,0 +1,156 @@
+/*
+ * List all snapshots, Restic's `snapshots` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_snapshots.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "snapshots",
+		Usage:     "List all snapshots",
+		ArgsUsage: "[snapshot ID...]",
+		Action:    runSnapshots,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "group-by",
+				Aliases: []string{"g"},
+				Usage:   "String for grouping snapshots by host,paths,tags",
+			},
+			&cli.IntFlag{
+				Name:  "latest",
+				Usage: "Only show the last `n` snapshots of each group",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the snapshots as JSON",
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// snapshotJSON is a snapshot with its ID, as printed by restic.
+type snapshotJSON struct {
+	*restic.Snapshot
+	ID      *restic.ID `json:"id"`
+	ShortID string     `json:"short_id"`
+}
+
+type snapshotGroupJSON struct {
+	GroupKey  restic.SnapshotGroupKey `json:"group_key"`
+	Snapshots []snapshotJSON          `json:"snapshots"`
+}
+
+func newSnapshotsJSON(list restic.Snapshots) []snapshotJSON {
+	out := []snapshotJSON{}
+	for _, sn := range list {
+		out = append(out, snapshotJSON{Snapshot: sn, ID: sn.ID(), ShortID: sn.ID().Str()})
+	}
+	return out
+}
+
+// latestSnapshots returns the n latest snapshots of list, or all of them if n
+// is 0, sorted from oldest to newest.
+func latestSnapshots(list restic.Snapshots, n int) restic.Snapshots {
+	// Snapshots sorts the newest snapshots first
+	sort.Sort(list)
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	sort.Sort(sort.Reverse(list))
+	return list
+}
+
+func runSnapshots(c *cli.Context) error {
+	ctx := context.Background()
+
+	latest := c.Int("latest")
+	if latest < 0 {
+		return errors.Fatal("--latest must be a positive number")
+	}
+
+	snapshots, err := findSnapshots(ctx, rapiRepo, c)
+	if err != nil {
+		return err
+	}
+
+	snapshotGroups, grouped, err := restic.GroupSnapshots(snapshots, c.String("group-by"))
+	if err != nil {
+		return err
+	}
+
+	if !grouped {
+		list := latestSnapshots(snapshots, latest)
+		if c.Bool("json") {
+			return printJSON(newSnapshotsJSON(list))
+		}
+		printSnapshots(os.Stdout, list, nil)
+		return nil
+	}
+
+	// print the groups in a stable order
+	keys := make([]string, 0, len(snapshotGroups))
+	for k := range snapshotGroups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	jsonGroups := []snapshotGroupJSON{}
+	for i, k := range keys {
+		var key restic.SnapshotGroupKey
+		if err := json.Unmarshal([]byte(k), &key); err != nil {
+			return err
+		}
+
+		list := latestSnapshots(snapshotGroups[k], latest)
+
+		if c.Bool("json") {
+			jsonGroups = append(jsonGroups, snapshotGroupJSON{
+				GroupKey:  key,
+				Snapshots: newSnapshotsJSON(list),
+			})
+			continue
+		}
+
+		if i > 0 {
+			rapi.Printf("\n")
+		}
+		if desc := groupKeyString(key); desc != "" {
+			rapi.Printf("snapshots for %s:\n", desc)
+		}
+		printSnapshots(os.Stdout, list, nil)
+	}
+
+	if c.Bool("json") {
+		return printJSON(jsonGroups)
+	}
+
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	rapi.Println(string(buf))
+	return nil
+}
@@ -0,0 +1,295 @@
+/*
+ * Scan the repository and show basic statistics, Restic's `stats` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_stats.go
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/crypto"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "stats",
+		Usage:     "Scan the repository and show basic statistics",
+		ArgsUsage: "[snapshot ID...]",
+		Action:    runStats,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "mode",
+				Usage: "Counting `mode`: restore-size, files-by-contents, blobs-per-file or raw-data",
+				Value: countModeRestoreSize,
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the statistics as JSON",
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, false)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runStats(c *cli.Context) error {
+	ctx := context.Background()
+
+	mode := c.String("mode")
+	switch mode {
+	case countModeRestoreSize, countModeUniqueFilesByContents, countModeBlobsPerFile, countModeRawData:
+	default:
+		return errors.Fatalf("unknown counting mode: %s (use the -h flag to get a list of supported modes)", mode)
+	}
+
+	if err := rapiRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	snapshots, err := findSnapshots(ctx, rapiRepo, c)
+	if err != nil {
+		return err
+	}
+
+	stats, err := snapshotStats(ctx, rapiRepo, snapshots, mode)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		buf, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		rapi.Println(string(buf))
+		return nil
+	}
+
+	rapi.Printf("Stats in %s mode:\n", mode)
+	rapi.Printf("  Snapshots processed:   %d\n", stats.SnapshotsCount)
+	if stats.TotalBlobCount > 0 {
+		rapi.Printf("     Total Blob Count:   %d\n", stats.TotalBlobCount)
+	}
+	if stats.TotalFileCount > 0 {
+		rapi.Printf("     Total File Count:   %d\n", stats.TotalFileCount)
+	}
+	rapi.Printf("           Total Size:   %s\n", humanize.Bytes(stats.TotalSize))
+	if mode == countModeRawData {
+		rapi.Printf("      Data Blobs Size:   %s\n", humanize.Bytes(stats.DataBlobSize))
+		rapi.Printf("          Stored Size:   %s\n", humanize.Bytes(stats.StoredSize))
+		rapi.Printf("  Encryption Overhead:   %s\n", humanize.Bytes(stats.EncryptionOverhead))
+	}
+
+	return nil
+}
+
+// snapshotStats walks the snapshots and counts their statistics in the
+// given mode.
+func snapshotStats(ctx context.Context, repo restic.Repository, snapshots restic.Snapshots, mode string) (*statsContainer, error) {
+	stats := &statsContainer{
+		mode:        mode,
+		uniqueFiles: make(map[fileID]struct{}),
+		fileBlobs:   make(map[string]restic.IDSet),
+		blobs:       restic.NewBlobSet(),
+	}
+
+	for _, sn := range snapshots {
+		if err := statsWalkSnapshot(ctx, sn, repo, stats); err != nil {
+			return nil, fmt.Errorf("error walking snapshot: %v", err)
+		}
+	}
+
+	if mode != countModeRawData {
+		return stats, nil
+	}
+
+	// the blob handles have been collected, but not yet counted
+	for h := range stats.blobs {
+		pbs := repo.Index().Lookup(h)
+		if len(pbs) == 0 {
+			return nil, fmt.Errorf("blob %v not found", h)
+		}
+
+		stats.TotalSize += uint64(pbs[0].DataLength())
+		stats.StoredSize += uint64(pbs[0].Length)
+		if h.Type == restic.DataBlob {
+			stats.DataBlobSize += uint64(pbs[0].DataLength())
+		}
+		stats.TotalBlobCount++
+	}
+
+	// each blob is encrypted separately, the nonce and the MAC are stored
+	// with it
+	stats.EncryptionOverhead = stats.TotalBlobCount * crypto.Extension
+
+	return stats, nil
+}
+
+func statsWalkSnapshot(ctx context.Context, snapshot *restic.Snapshot, repo restic.Repository, stats *statsContainer) error {
+	if snapshot.Tree == nil {
+		return fmt.Errorf("snapshot %s has nil tree", snapshot.ID().Str())
+	}
+
+	stats.SnapshotsCount++
+	// hard links are restored once per snapshot
+	stats.hardlinks = make(map[hardlinkKey]struct{})
+
+	if stats.mode == countModeRawData {
+		// count just the sizes of unique blobs; we don't need to walk the tree
+		// ourselves in this case, since a nifty function does it for us
+		return restic.FindUsedBlobs(ctx, repo, restic.IDs{*snapshot.Tree}, stats.blobs, nil)
+	}
+
+	err := walker.Walk(ctx, repo, *snapshot.Tree, restic.NewIDSet(), statsWalkTree(repo, stats))
+	if err != nil {
+		return fmt.Errorf("walking tree %s: %v", *snapshot.Tree, err)
+	}
+
+	return nil
+}
+
+func statsWalkTree(repo restic.Repository, stats *statsContainer) walker.WalkFunc {
+	return func(parentTreeID restic.ID, npath string, node *restic.Node, nodeErr error) (bool, error) {
+		if nodeErr != nil {
+			return true, nodeErr
+		}
+		if node == nil {
+			return true, nil
+		}
+
+		switch stats.mode {
+		case countModeUniqueFilesByContents:
+			// only count this file if we haven't visited it before
+			fid := makeFileIDByContents(node)
+			if _, ok := stats.uniqueFiles[fid]; !ok && node.Type == "file" {
+				// mark the file as visited
+				stats.uniqueFiles[fid] = struct{}{}
+
+				// simply count the size of each unique file (unique by contents only)
+				stats.TotalSize += node.Size
+				stats.TotalFileCount++
+			}
+
+		case countModeBlobsPerFile:
+			if node.Type != "file" {
+				break
+			}
+
+			// a file is unique by both contents and path in this mode
+			if _, ok := stats.fileBlobs[npath]; !ok {
+				stats.fileBlobs[npath] = restic.NewIDSet()
+				stats.TotalFileCount++
+			}
+
+			for _, blobID := range node.Content {
+				if stats.fileBlobs[npath].Has(blobID) {
+					continue
+				}
+
+				// is always a data blob since we're accessing it via a file's Content array
+				blobSize, found := repo.LookupBlobSize(blobID, restic.DataBlob)
+				if !found {
+					return true, fmt.Errorf("blob %s not found for tree %s", blobID, parentTreeID)
+				}
+
+				// count the blob's size, then add this blob by this file
+				// (path) so we don't double-count it
+				stats.TotalSize += uint64(blobSize)
+				stats.fileBlobs[npath].Insert(blobID)
+				// this mode also counts total unique blob _references_ per file
+				stats.TotalBlobCount++
+			}
+
+		case countModeRestoreSize:
+			// duplicate files are restored too, so they are always counted
+			if node.Type == "file" {
+				stats.TotalFileCount++
+			}
+
+			stats.TotalSize += restoreSize(node, stats.hardlinks)
+		}
+
+		// identical trees at other paths are restored and listed again, only
+		// the unique files don't need to visit them twice
+		return stats.mode == countModeUniqueFilesByContents, nil
+	}
+}
+
+// hardlinkKey identifies the inode of a file with several hard links.
+type hardlinkKey struct {
+	deviceID, inode uint64
+}
+
+// restoreSize returns the number of bytes restored for node. A file with
+// several hard links is only counted once, seen holds the ones visited.
+func restoreSize(node *restic.Node, seen map[hardlinkKey]struct{}) uint64 {
+	if node.Type == "file" && node.Links > 1 {
+		key := hardlinkKey{deviceID: node.DeviceID, inode: node.Inode}
+		if _, ok := seen[key]; ok {
+			return 0
+		}
+		seen[key] = struct{}{}
+	}
+	return node.Size
+}
+
+// statsContainer holds information during a walk of a repository
+// to collect information about it, as well as state needed
+// for a successful and efficient walk.
+type statsContainer struct {
+	SnapshotsCount int    `json:"snapshots_count"`
+	TotalSize      uint64 `json:"total_size"`
+	TotalFileCount uint64 `json:"total_file_count"`
+	TotalBlobCount uint64 `json:"total_blob_count,omitempty"`
+
+	// The raw-data mode counts the plaintext size of the blobs in
+	// TotalSize, the following sizes are only set in that mode.
+
+	// DataBlobSize is the plaintext size of the file contents, TotalSize
+	// also includes the trees.
+	DataBlobSize uint64 `json:"data_blob_size,omitempty"`
+	// StoredSize is the size of the blobs in the pack files, after
+	// compression and encryption.
+	StoredSize uint64 `json:"stored_size,omitempty"`
+	// EncryptionOverhead is the size of the nonces and MACs of the blobs,
+	// included in StoredSize.
+	EncryptionOverhead uint64 `json:"encryption_overhead,omitempty"`
+
+	mode string
+
+	// uniqueFiles marks visited files according to their
+	// contents (hashed sequence of content blob IDs)
+	uniqueFiles map[fileID]struct{}
+
+	// hardlinks marks the hard linked files visited in the
+	// current snapshot
+	hardlinks map[hardlinkKey]struct{}
+
+	// fileBlobs maps a file name (path) to the set of
+	// blobs that have been seen as a part of the file
+	fileBlobs map[string]restic.IDSet
+
+	// blobs is used to count individual unique blobs,
+	// independent of references to files
+	blobs restic.BlobSet
+}
+
+const (
+	countModeRestoreSize           = "restore-size"
+	countModeUniqueFilesByContents = "files-by-contents"
+	countModeBlobsPerFile          = "blobs-per-file"
+	countModeRawData               = "raw-data"
+)
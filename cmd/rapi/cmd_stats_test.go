@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// testSnapshotDuplicateDirs saves a snapshot with two identical
+// subdirectories, each holding one file of 100 bytes.
+func testSnapshotDuplicateDirs(t testing.TB, repo restic.Repository) *restic.Snapshot {
+	ctx := context.TODO()
+
+	data := rtest.Random(23, 100)
+	blobID, _, err := repo.SaveBlob(ctx, restic.DataBlob, data, restic.ID{}, false)
+	rtest.OK(t, err)
+
+	subtree := restic.NewTree(1)
+	rtest.OK(t, subtree.Insert(&restic.Node{Name: "file", Type: "file", Mode: 0644, Size: 100, Content: restic.IDs{blobID}}))
+	subtreeID, err := repo.SaveTree(ctx, subtree)
+	rtest.OK(t, err)
+
+	root := restic.NewTree(2)
+	for _, name := range []string{"a", "b"} {
+		rtest.OK(t, root.Insert(&restic.Node{Name: name, Type: "dir", Mode: 0755, Subtree: &subtreeID}))
+	}
+	rootID, err := repo.SaveTree(ctx, root)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(ctx))
+
+	sn, err := restic.NewSnapshot([]string{"/"}, nil, "localhost", time.Now())
+	rtest.OK(t, err)
+	sn.Tree = &rootID
+	return sn
+}
+
+func TestSnapshotStatsDuplicateDirs(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	snapshots := restic.Snapshots{testSnapshotDuplicateDirs(t, repo)}
+
+	var tests = []struct {
+		mode      string
+		files     uint64
+		size      uint64
+		blobCount uint64
+	}{
+		{countModeRestoreSize, 2, 200, 0},
+		{countModeBlobsPerFile, 2, 200, 2},
+		{countModeUniqueFilesByContents, 1, 100, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			stats, err := snapshotStats(context.TODO(), repo, snapshots, test.mode)
+			rtest.OK(t, err)
+			rtest.Equals(t, test.files, stats.TotalFileCount)
+			rtest.Equals(t, test.size, stats.TotalSize)
+			rtest.Equals(t, test.blobCount, stats.TotalBlobCount)
+		})
+	}
+}
+
+func TestWalkSnapshotInfoDuplicateDirs(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	sn := testSnapshotDuplicateDirs(t, repo)
+
+	info, err := walkSnapshotInfo(context.TODO(), repo, *sn.Tree)
+	rtest.OK(t, err)
+	rtest.Equals(t, snapshotInfo{
+		// the root tree, the subtree and the data blob
+		blobCount:   3,
+		dataSize:    100,
+		totalFiles:  2,
+		uniqueFiles: 1,
+		size:        200,
+	}, info)
+}
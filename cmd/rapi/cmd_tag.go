@@ -0,0 +1,153 @@
+/*
+ * Modify the tags of snapshots, Restic's `tag` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_tag.go
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:      "tag",
+		Usage:     "Modify the tags, host or paths of snapshots",
+		ArgsUsage: "[snapshot ID...]",
+		Action:    runTag,
+		Flags: append([]cli.Flag{
+			&cli.GenericFlag{
+				Name:  "set",
+				Usage: "`tags` which will replace the existing tags in the format `tag[,tag,...]` (can be given multiple times)",
+				Value: &restic.TagLists{},
+			},
+			&cli.GenericFlag{
+				Name:  "add",
+				Usage: "`tags` which will be added to the existing tags in the format `tag[,tag,...]` (can be given multiple times)",
+				Value: &restic.TagLists{},
+			},
+			&cli.GenericFlag{
+				Name:  "remove",
+				Usage: "`tags` which will be removed from the existing tags in the format `tag[,tag,...]` (can be given multiple times)",
+				Value: &restic.TagLists{},
+			},
+			&cli.StringFlag{
+				Name:  "set-host",
+				Usage: "`host` which will replace the snapshot host",
+			},
+			&cli.StringSliceFlag{
+				Name:  "set-path",
+				Usage: "`path` which will replace the snapshot paths (can be given multiple times)",
+			},
+		}, snapshotFilterFlags()...),
+		Before: func(c *cli.Context) error {
+			return setupAppLocked(c, true)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+// snapshotChanges are the modifications made by `rapi tag`.
+type snapshotChanges struct {
+	setTags    restic.TagList
+	addTags    restic.TagList
+	removeTags restic.TagList
+	host       string
+	paths      []string
+}
+
+// apply modifies sn and reports whether anything was changed.
+func (ch snapshotChanges) apply(sn *restic.Snapshot) (changed bool) {
+	if ch.setTags != nil {
+		// set the tags to exactly the given ones, in the given order
+		if !sameStrings(sn.Tags, ch.setTags) {
+			sn.Tags = ch.setTags
+			changed = true
+		}
+	} else {
+		changed = sn.AddTags(ch.addTags)
+		if sn.RemoveTags(ch.removeTags) {
+			changed = true
+		}
+	}
+
+	if ch.host != "" && sn.Hostname != ch.host {
+		sn.Hostname = ch.host
+		changed = true
+	}
+
+	if len(ch.paths) > 0 && !sameStrings(sn.Paths, ch.paths) {
+		sn.Paths = ch.paths
+		changed = true
+	}
+
+	return changed
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func runTag(c *cli.Context) error {
+	ctx := context.Background()
+
+	var ch snapshotChanges
+	if c.IsSet("set") {
+		// --set "" removes all the tags
+		ch.setTags = c.Generic("set").(*restic.TagLists).Flatten()
+	}
+	ch.addTags = c.Generic("add").(*restic.TagLists).Flatten()
+	ch.removeTags = c.Generic("remove").(*restic.TagLists).Flatten()
+	ch.host = c.String("set-host")
+	ch.paths = c.StringSlice("set-path")
+
+	if ch.setTags == nil && len(ch.addTags) == 0 && len(ch.removeTags) == 0 && ch.host == "" && len(ch.paths) == 0 {
+		return errors.Fatal("nothing to do, use --set, --add, --remove, --set-host or --set-path")
+	}
+	if ch.setTags != nil && (len(ch.addTags) > 0 || len(ch.removeTags) > 0) {
+		return errors.Fatal("--set and --add/--remove cannot be given at the same time")
+	}
+
+	snapshots, err := findSnapshots(ctx, rapiRepo, c)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	for _, sn := range snapshots {
+		oldID := sn.ID()
+		if !ch.apply(sn) {
+			continue
+		}
+
+		id, err := restic.ReplaceSnapshot(ctx, rapiRepo, sn)
+		if err != nil {
+			return errors.Fatalf("unable to modify snapshot %s: %v", oldID.Str(), err)
+		}
+		rapi.Printf("snapshot %s saved as %s\n", oldID.Str(), id.Str())
+		changed++
+	}
+
+	if changed == 0 {
+		rapi.Println("no snapshots were modified")
+	} else {
+		rapi.Printf("modified %d snapshots\n", changed)
+	}
+
+	return nil
+}
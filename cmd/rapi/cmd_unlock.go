@@ -0,0 +1,50 @@
+/*
+ * Remove stale locks, Restic's `unlock` command.
+ *
+ * See https://github.com/rubiojr/rapi/tree/master/docs/tooling
+ *
+ * Original source from https://github.com/restic/restic/blob/31b8d7a63999746623c8940f8200205a52a2b81b/cmd/restic/cmd_unlock.go
+ */
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+func init() {
+	cmd := &cli.Command{
+		Name:   "unlock",
+		Usage:  "Remove locks other processes created",
+		Action: runUnlock,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "remove-all",
+				Usage: "Remove all locks, even non-stale ones",
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupApp(c)
+		},
+	}
+	appCommands = append(appCommands, cmd)
+}
+
+func runUnlock(c *cli.Context) error {
+	ctx := context.Background()
+
+	fn := restic.RemoveStaleLocks
+	if c.Bool("remove-all") {
+		fn = restic.RemoveAllLocks
+	}
+
+	if err := fn(ctx, rapiRepo); err != nil {
+		return err
+	}
+
+	rapi.Println("successfully removed locks")
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/restic"
+	"golang.org/x/sync/errgroup"
+)
+
+const numDeleteWorkers = 8
+
+// deleteFiles removes the files of type t with the given IDs from the
+// backend. Errors are printed as warnings and do not stop the deletion of the
+// remaining files.
+func deleteFiles(ctx context.Context, repo restic.Repository, ids restic.IDSet, t restic.FileType) {
+	_ = deleteFilesChecked(ctx, repo, ids, t, true)
+}
+
+// deleteFilesChecked removes the files of type t with the given IDs from the
+// backend and returns the first error found, unless ignoreError is set.
+func deleteFilesChecked(ctx context.Context, repo restic.Repository, ids restic.IDSet, t restic.FileType, ignoreError bool) error {
+	wg, ctx := errgroup.WithContext(ctx)
+
+	ch := make(chan restic.Handle)
+	wg.Go(func() error {
+		defer close(ch)
+		for id := range ids {
+			select {
+			case ch <- restic.Handle{Type: t, Name: id.String()}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	for i := 0; i < numDeleteWorkers; i++ {
+		wg.Go(func() error {
+			for h := range ch {
+				err := repo.Backend().Remove(ctx, h)
+				if err != nil {
+					rapi.Warnf("unable to remove %v from the repository: %v\n", h, err)
+					if !ignoreError {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	return wg.Wait()
+}
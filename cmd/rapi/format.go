@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/ui/table"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// printSnapshots prints a table with the snapshots in list. When reasons is
+// not nil, it must have the same length as list and an additional column
+// with the reasons to keep each snapshot is printed.
+func printSnapshots(w io.Writer, list restic.Snapshots, reasons []restic.KeepReason) {
+	type row struct {
+		ID      string
+		Time    string
+		Host    string
+		Tags    []string
+		Paths   []string
+		Reasons []string
+	}
+
+	tab := table.New()
+	tab.AddColumn("ID", "{{ .ID }}")
+	tab.AddColumn("Time", "{{ .Time }}")
+	tab.AddColumn("Host", "{{ .Host }}")
+	tab.AddColumn("Tags", `{{ join .Tags "\n" }}`)
+	if reasons != nil {
+		tab.AddColumn("Reasons", `{{ join .Reasons "\n" }}`)
+	}
+	tab.AddColumn("Paths", `{{ join .Paths "\n" }}`)
+
+	for i, sn := range list {
+		r := row{
+			ID:    sn.ID().Str(),
+			Time:  sn.Time.Local().Format(rapi.TimeFormat),
+			Host:  sn.Hostname,
+			Tags:  sn.Tags,
+			Paths: sn.Paths,
+		}
+		if reasons != nil {
+			r.Reasons = reasons[i].Matches
+		}
+		tab.AddRow(r)
+	}
+
+	tab.AddFooter(fmt.Sprintf("%d snapshots", len(list)))
+	_ = tab.Write(w)
+}
+
+// groupKeyString returns a human readable description of a group key
+// returned by restic.GroupSnapshots.
+func groupKeyString(key restic.SnapshotGroupKey) string {
+	var parts []string
+	if key.Hostname != "" {
+		parts = append(parts, fmt.Sprintf("host [%s]", key.Hostname))
+	}
+	if len(key.Tags) > 0 {
+		parts = append(parts, fmt.Sprintf("tags [%s]", strings.Join(key.Tags, ", ")))
+	}
+	if len(key.Paths) > 0 {
+		parts = append(parts, fmt.Sprintf("paths [%s]", strings.Join(key.Paths, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatNode returns the path of node, or a line similar to `ls -l` when
+// long is true.
+func formatNode(path string, n *restic.Node, long bool) string {
+	if !long {
+		return path
+	}
+
+	var mode os.FileMode
+	var target string
+
+	switch n.Type {
+	case "file":
+		mode = 0
+	case "dir":
+		mode = os.ModeDir
+	case "symlink":
+		mode = os.ModeSymlink
+		target = fmt.Sprintf(" -> %v", n.LinkTarget)
+	case "dev":
+		mode = os.ModeDevice
+	case "chardev":
+		mode = os.ModeDevice | os.ModeCharDevice
+	case "fifo":
+		mode = os.ModeNamedPipe
+	case "socket":
+		mode = os.ModeSocket
+	}
+
+	return fmt.Sprintf("%s %5d %5d %6d %s %s%s",
+		mode|n.Mode, n.UID, n.GID, n.Size,
+		n.ModTime.Local().Format(rapi.TimeFormat), path,
+		target)
+}
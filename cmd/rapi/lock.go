@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/ui/signals"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
+)
+
+// globalLocks are the repository locks held by the running command, they
+// are released by unlockAll when rapi exits.
+var globalLocks struct {
+	locks []*repository.Lock
+	sync.Mutex
+	sync.Once
+}
+
+// setupAppLocked opens the repository like setupApp and locks it, unless
+// --no-lock was given.
+func setupAppLocked(c *cli.Context, exclusive bool) error {
+	if err := setupApp(c); err != nil {
+		return err
+	}
+	return lockRepo(context.Background(), rapiRepo, exclusive)
+}
+
+// lockRepo locks repo for the rest of the command. The lock is refreshed in
+// the background and released on exit, also when rapi is interrupted.
+func lockRepo(ctx context.Context, repo restic.Repository, exclusive bool) error {
+	if globalOptions.NoLock {
+		return nil
+	}
+
+	if err := lockRepoWithoutInterrupt(ctx, repo, exclusive); err != nil {
+		return err
+	}
+
+	globalLocks.Do(func() {
+		go func() {
+			<-signals.GetInterruptChannel()
+			unlockAll()
+			os.Exit(130)
+		}()
+	})
+
+	return nil
+}
+
+// lockRepoWithoutInterrupt is like lockRepo, but does not handle interrupts. It is
+// used by commands listening on the interrupt channel themselves, which
+// must return from their action so that the lock is released on exit.
+func lockRepoWithoutInterrupt(ctx context.Context, repo restic.Repository, exclusive bool) error {
+	if globalOptions.NoLock {
+		return nil
+	}
+
+	lock, err := repository.NewLock(ctx, repo, exclusive)
+	if restic.IsAlreadyLocked(err) {
+		return errors.Fatalf("%v\nthe `unlock` command can be used to remove stale locks", err)
+	}
+	if err != nil {
+		return errors.Fatalf("unable to create lock in repository: %v", err)
+	}
+
+	globalLocks.Lock()
+	globalLocks.locks = append(globalLocks.locks, lock)
+	globalLocks.Unlock()
+
+	go func() {
+		<-lock.Lost()
+		rapi.Warnf("the repository lock could not be refreshed, other processes may modify the repository\n")
+	}()
+
+	return nil
+}
+
+// unlockAll releases all the locks taken by lockRepo.
+func unlockAll() {
+	globalLocks.Lock()
+	defer globalLocks.Unlock()
+
+	for _, lock := range globalLocks.locks {
+		if err := lock.Unlock(); err != nil {
+			rapi.Warnf("%v\n", err)
+		}
+	}
+	globalLocks.locks = nil
+}
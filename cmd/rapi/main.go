@@ -38,6 +38,11 @@ func main() {
 				Required:    false,
 				Destination: &globalOptions.Password,
 			},
+			&cli.BoolFlag{
+				Name:        "no-lock",
+				Usage:       "Do not lock the repository, this allows some operations on read-only repositories",
+				Destination: &globalOptions.NoLock,
+			},
 			&cli.BoolFlag{
 				Name:     "debug",
 				Aliases:  []string{"d"},
@@ -49,6 +54,7 @@ func main() {
 
 	app.Commands = append(app.Commands, appCommands...)
 	err = app.Run(os.Args)
+	unlockAll()
 	if err != nil {
 		println(fmt.Sprintf("\n%v", err))
 		os.Exit(1)
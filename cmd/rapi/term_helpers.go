@@ -2,9 +2,11 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/muesli/reflow/padding"
 	"github.com/muesli/termenv"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 const (
@@ -21,3 +23,7 @@ func colorize(str, color string) string {
 func printRow(header, value, color string) {
 	fmt.Printf("%s %s\n", padding.String(colorize(header+":", color), colPadding), value)
 }
+
+func stdoutIsTerminal() bool {
+	return terminal.IsTerminal(int(os.Stdout.Fd()))
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+
 	"github.com/minio/sha256-simd"
+	"github.com/rubiojr/rapi"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/repository"
 	"github.com/rubiojr/rapi/restic"
+	"github.com/urfave/cli/v2"
 )
 
 // fileID is a 256-bit hash that distinguishes unique files.
@@ -17,3 +23,108 @@ func makeFileIDByContents(node *restic.Node) fileID {
 	}
 	return sha256.Sum256(bb)
 }
+
+// findSnapshot resolves a snapshot ID, a unique prefix of it or "latest" to
+// the ID of a snapshot in repo.
+func findSnapshot(ctx context.Context, repo restic.Repository, s string) (restic.ID, error) {
+	if s == "latest" {
+		id, err := restic.FindLatestSnapshot(ctx, repo, []string{}, []restic.TagList{}, []string{}, nil)
+		if err != nil {
+			return restic.ID{}, errors.Fatalf("latest snapshot not found: %v", err)
+		}
+		return id, nil
+	}
+
+	id, err := restic.FindSnapshot(ctx, repo, s)
+	if err != nil {
+		return restic.ID{}, errors.Fatalf("could not find snapshot %q: %v", s, err)
+	}
+	return id, nil
+}
+
+// snapshotFilterFlags returns the flags used to select snapshots by host, tag
+// and path.
+func snapshotFilterFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "host",
+			Aliases: []string{"H"},
+			Usage:   "Only consider snapshots for this `host` (can be specified multiple times)",
+		},
+		// a GenericFlag as StringSliceFlag splits the tag lists on commas
+		&cli.GenericFlag{
+			Name:  "tag",
+			Usage: "Only consider snapshots which include this `taglist` of comma separated tags (can be specified multiple times)",
+			Value: &restic.TagLists{},
+		},
+		&cli.StringSliceFlag{
+			Name:  "path",
+			Usage: "Only consider snapshots which include this (absolute) `path` (can be specified multiple times)",
+		},
+	}
+}
+
+// snapshotFilter returns the hosts, tags and paths given with the flags from
+// snapshotFilterFlags.
+func snapshotFilter(c *cli.Context) (hosts []string, tags []restic.TagList, paths []string) {
+	if l, ok := c.Generic("tag").(*restic.TagLists); ok {
+		tags = *l
+	}
+	return c.StringSlice("host"), tags, c.StringSlice("path")
+}
+
+// findSnapshots loads the snapshots given as arguments or, when there are
+// none, all the snapshots matching the filter flags.
+func findSnapshots(ctx context.Context, repo restic.Repository, c *cli.Context) (restic.Snapshots, error) {
+	if c.NArg() == 0 {
+		hosts, tags, paths := snapshotFilter(c)
+		return restic.FindFilteredSnapshots(ctx, repo, hosts, tags, paths)
+	}
+
+	var snapshots restic.Snapshots
+	for _, arg := range c.Args().Slice() {
+		sn, err := loadSnapshotArg(ctx, repo, arg)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, sn)
+	}
+
+	return snapshots, nil
+}
+
+// fromRepoFlags returns the flags used to open a second repository, the
+// source of the data for commands like copy.
+func fromRepoFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "from-repo",
+			EnvVars: []string{"RESTIC_FROM_REPOSITORY"},
+			Usage:   "Source `repository`",
+		},
+		&cli.StringFlag{
+			Name:    "from-password",
+			EnvVars: []string{"RESTIC_FROM_PASSWORD"},
+			Usage:   "Source repository password",
+		},
+		&cli.StringFlag{
+			Name:  "from-password-file",
+			Usage: "`file` to read the source repository password from",
+		},
+	}
+}
+
+// openFromRepo opens the repository given with the flags from
+// fromRepoFlags.
+func openFromRepo(c *cli.Context) (*repository.Repository, error) {
+	if c.String("from-repo") == "" {
+		return nil, errors.Fatal("please specify a source repository location (--from-repo)")
+	}
+
+	opts := globalOptions
+	opts.Repo = c.String("from-repo")
+	opts.Password = c.String("from-password")
+	opts.PasswordFile = c.String("from-password-file")
+
+	return rapi.OpenRepository(opts)
+}
@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Repositories with version 2 compress blobs and unpacked files with zstd
+// before encrypting them. The encoder and decoder are safe for concurrent use
+// and shared by all callers.
+var (
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderOnce sync.Once
+
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderOnce sync.Once
+)
+
+func getZstdEncoder() *zstd.Encoder {
+	zstdEncoderOnce.Do(func() {
+		opts := []zstd.EOption{
+			zstd.WithEncoderLevel(zstd.SpeedDefault),
+			// Disable CRC, we have enough checks in place, makes the
+			// compressed data four bytes shorter.
+			zstd.WithEncoderCRC(false),
+			// Set a window of 512kbyte, so we have good lookbehind for usual
+			// blob sizes.
+			zstd.WithWindowSize(512 * 1024),
+		}
+
+		enc, err := zstd.NewWriter(nil, opts...)
+		if err != nil {
+			panic(err)
+		}
+		zstdEncoder = enc
+	})
+	return zstdEncoder
+}
+
+func getZstdDecoder() *zstd.Decoder {
+	zstdDecoderOnce.Do(func() {
+		opts := []zstd.DOption{
+			// Limit the maximum decompressed memory. Set to a very high,
+			// conservative value.
+			zstd.WithDecoderMaxMemory(16 * 1024 * 1024 * 1024),
+		}
+
+		dec, err := zstd.NewReader(nil, opts...)
+		if err != nil {
+			panic(err)
+		}
+		zstdDecoder = dec
+	})
+	return zstdDecoder
+}
+
+// Compress appends the zstd compressed data to dst and returns the result.
+func Compress(dst, data []byte) []byte {
+	return getZstdEncoder().EncodeAll(data, dst)
+}
+
+// Decompress appends the decompressed zstd data to dst and returns the
+// result.
+func Decompress(dst, data []byte) ([]byte, error) {
+	return getZstdDecoder().DecodeAll(data, dst)
+}
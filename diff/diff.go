@@ -0,0 +1,348 @@
+// Package diff compares the trees of two snapshots.
+//
+// The trees are walked side by side, subtrees with the same ID on both sides
+// are not compared as their contents are identical. Every added, removed or
+// modified item is reported with a Change, the totals and the size of the
+// data only referenced by one of the sides are returned as Stats.
+package diff
+
+import (
+	"context"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+	"github.com/rubiojr/rapi/walker"
+)
+
+// Modifiers used in Change.Modifier. The modifiers of a changed item are
+// combined, "MU" is a file whose content and metadata changed.
+const (
+	Added       = "+"
+	Removed     = "-"
+	Modified    = "M"
+	Metadata    = "U"
+	TypeChanged = "T"
+)
+
+// Change describes an item which differs between the two trees. The path
+// of directories ends with a slash.
+type Change struct {
+	Path     string `json:"path"`
+	Modifier string `json:"modifier"`
+}
+
+// Stat counts the items and blobs on one side of the comparison.
+type Stat struct {
+	Files     int    `json:"files"`
+	Dirs      int    `json:"dirs"`
+	Others    int    `json:"others"`
+	DataBlobs int    `json:"data_blobs"`
+	TreeBlobs int    `json:"tree_blobs"`
+	Bytes     uint64 `json:"bytes"`
+}
+
+func (s *Stat) add(node *restic.Node) {
+	switch node.Type {
+	case "file":
+		s.Files++
+	case "dir":
+		s.Dirs++
+	default:
+		s.Others++
+	}
+}
+
+// Stats is the result of a comparison. Added and Removed count the items
+// only present in the second and first tree, and the blobs only referenced
+// by them.
+type Stats struct {
+	ChangedFiles int  `json:"changed_files"`
+	Added        Stat `json:"added"`
+	Removed      Stat `json:"removed"`
+}
+
+// Options configures a comparison.
+type Options struct {
+	// ShowMetadata reports items whose metadata changed, like the
+	// modification time or the owner.
+	ShowMetadata bool
+	// Change is called for every item which differs, in path order. It is
+	// optional.
+	Change func(Change)
+}
+
+// Snapshots compares the trees of the snapshots sn1 and sn2. The index of
+// repo must be loaded.
+func Snapshots(ctx context.Context, repo restic.Repository, sn1, sn2 *restic.Snapshot, opts Options) (*Stats, error) {
+	if sn1.Tree == nil || sn2.Tree == nil {
+		return nil, errors.New("snapshot has no tree")
+	}
+
+	return Trees(ctx, repo, *sn1.Tree, *sn2.Tree, opts)
+}
+
+// Trees compares the trees with the IDs id1 and id2. The index of repo must
+// be loaded.
+func Trees(ctx context.Context, repo restic.Repository, id1, id2 restic.ID, opts Options) (*Stats, error) {
+	if id1.Equal(id2) {
+		return &Stats{}, nil
+	}
+
+	c := &comparer{
+		repo:        repo,
+		opts:        opts,
+		blobsBefore: restic.NewBlobSet(),
+		blobsAfter:  restic.NewBlobSet(),
+		blobsCommon: restic.NewBlobSet(),
+		walked:      restic.NewIDSet(),
+	}
+
+	c.blobsBefore.Insert(restic.BlobHandle{ID: id1, Type: restic.TreeBlob})
+	c.blobsAfter.Insert(restic.BlobHandle{ID: id2, Type: restic.TreeBlob})
+
+	err := c.diffTree(ctx, "/", id1, id2)
+	if err != nil {
+		return nil, err
+	}
+
+	both := c.blobsBefore.Intersect(c.blobsAfter)
+	c.blobsCommon.Merge(both)
+
+	c.addBlobStats(c.blobsBefore.Sub(c.blobsCommon), &c.stats.Removed)
+	c.addBlobStats(c.blobsAfter.Sub(c.blobsCommon), &c.stats.Added)
+
+	return &c.stats, nil
+}
+
+type comparer struct {
+	repo  restic.Repository
+	opts  Options
+	stats Stats
+
+	blobsBefore restic.BlobSet
+	blobsAfter  restic.BlobSet
+	blobsCommon restic.BlobSet
+
+	// walked holds the identical subtrees whose blobs are already in
+	// blobsCommon
+	walked restic.IDSet
+}
+
+func (c *comparer) change(p, modifier string) {
+	if c.opts.Change != nil {
+		c.opts.Change(Change{Path: p, Modifier: modifier})
+	}
+}
+
+func (c *comparer) addBlobStats(blobs restic.BlobSet, stat *Stat) {
+	for h := range blobs {
+		switch h.Type {
+		case restic.DataBlob:
+			stat.DataBlobs++
+		case restic.TreeBlob:
+			stat.TreeBlobs++
+		}
+
+		size, found := c.repo.LookupBlobSize(h.ID, h.Type)
+		if !found {
+			debug.Log("unable to find blob size for %v", h)
+			continue
+		}
+		stat.Bytes += uint64(size)
+	}
+}
+
+func addBlobs(blobs restic.BlobSet, node *restic.Node) {
+	switch node.Type {
+	case "file":
+		for _, id := range node.Content {
+			blobs.Insert(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+		}
+	case "dir":
+		blobs.Insert(restic.BlobHandle{ID: *node.Subtree, Type: restic.TreeBlob})
+	}
+}
+
+// collectCommon adds the blobs referenced by the identical subtree id to
+// blobsCommon.
+func (c *comparer) collectCommon(ctx context.Context, id restic.ID) error {
+	if c.walked.Has(id) {
+		return nil
+	}
+	c.blobsCommon.Insert(restic.BlobHandle{ID: id, Type: restic.TreeBlob})
+
+	err := walker.Walk(ctx, c.repo, id, c.walked, func(_ restic.ID, _ string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			return false, err
+		}
+		if node == nil {
+			return false, nil
+		}
+
+		addBlobs(c.blobsCommon, node)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.walked.Insert(id)
+	return nil
+}
+
+// collectDir reports all items in the subtree id as added or removed.
+func (c *comparer) collectDir(ctx context.Context, prefix string, id restic.ID, modifier string, stat *Stat, blobs restic.BlobSet) error {
+	return walker.Walk(ctx, c.repo, id, nil, func(_ restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			return false, err
+		}
+		if node == nil {
+			return false, nil
+		}
+
+		name := path.Join(prefix, nodepath)
+		if node.Type == "dir" {
+			name += "/"
+		}
+
+		stat.add(node)
+		addBlobs(blobs, node)
+		c.change(name, modifier)
+		return false, nil
+	})
+}
+
+func uniqueNodeNames(tree1, tree2 *restic.Tree) (tree1Nodes, tree2Nodes map[string]*restic.Node, uniqueNames []string) {
+	names := make(map[string]struct{})
+	tree1Nodes = make(map[string]*restic.Node)
+	for _, node := range tree1.Nodes {
+		tree1Nodes[node.Name] = node
+		names[node.Name] = struct{}{}
+	}
+
+	tree2Nodes = make(map[string]*restic.Node)
+	for _, node := range tree2.Nodes {
+		tree2Nodes[node.Name] = node
+		names[node.Name] = struct{}{}
+	}
+
+	uniqueNames = make([]string, 0, len(names))
+	for name := range names {
+		uniqueNames = append(uniqueNames, name)
+	}
+
+	sort.Strings(uniqueNames)
+	return tree1Nodes, tree2Nodes, uniqueNames
+}
+
+// sameMetadata returns true if the nodes only differ in their content.
+func sameMetadata(node1, node2 restic.Node) bool {
+	node1.Content, node2.Content = nil, nil
+	node1.Subtree, node2.Subtree = nil, nil
+	node1.Size, node2.Size = 0, 0
+	return node1.Equals(node2)
+}
+
+func (c *comparer) diffTree(ctx context.Context, prefix string, id1, id2 restic.ID) error {
+	debug.Log("diffing %v to %v", id1, id2)
+
+	tree1, err := c.repo.LoadTree(ctx, id1)
+	if err != nil {
+		return err
+	}
+
+	tree2, err := c.repo.LoadTree(ctx, id2)
+	if err != nil {
+		return err
+	}
+
+	tree1Nodes, tree2Nodes, names := uniqueNodeNames(tree1, tree2)
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		node1, t1 := tree1Nodes[name]
+		node2, t2 := tree2Nodes[name]
+
+		itemPath := path.Join(prefix, name)
+
+		switch {
+		case t1 && t2:
+			addBlobs(c.blobsBefore, node1)
+			addBlobs(c.blobsAfter, node2)
+
+			mod := ""
+			if node1.Type != node2.Type {
+				mod += TypeChanged
+			}
+
+			if node1.Type == "file" && node2.Type == "file" && !reflect.DeepEqual(node1.Content, node2.Content) {
+				mod += Modified
+				c.stats.ChangedFiles++
+			}
+
+			if c.opts.ShowMetadata && !sameMetadata(*node1, *node2) {
+				mod += Metadata
+			}
+
+			if mod != "" {
+				name := itemPath
+				if node2.Type == "dir" {
+					name += "/"
+				}
+				c.change(name, mod)
+			}
+
+			switch {
+			case node1.Type == "dir" && node2.Type == "dir":
+				if node1.Subtree.Equal(*node2.Subtree) {
+					err = c.collectCommon(ctx, *node1.Subtree)
+				} else {
+					err = c.diffTree(ctx, itemPath, *node1.Subtree, *node2.Subtree)
+				}
+			case node1.Type == "dir":
+				// the contents of a dir replaced by another type are gone
+				err = c.collectDir(ctx, itemPath, *node1.Subtree, Removed, &c.stats.Removed, c.blobsBefore)
+			case node2.Type == "dir":
+				err = c.collectDir(ctx, itemPath, *node2.Subtree, Added, &c.stats.Added, c.blobsAfter)
+			}
+		case t1 && !t2:
+			err = c.removed(ctx, itemPath, node1)
+		case !t1 && t2:
+			err = c.added(ctx, itemPath, node2)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *comparer) removed(ctx context.Context, itemPath string, node *restic.Node) error {
+	return c.addedOrRemoved(ctx, itemPath, node, Removed, &c.stats.Removed, c.blobsBefore)
+}
+
+func (c *comparer) added(ctx context.Context, itemPath string, node *restic.Node) error {
+	return c.addedOrRemoved(ctx, itemPath, node, Added, &c.stats.Added, c.blobsAfter)
+}
+
+func (c *comparer) addedOrRemoved(ctx context.Context, itemPath string, node *restic.Node, modifier string, stat *Stat, blobs restic.BlobSet) error {
+	stat.add(node)
+	addBlobs(blobs, node)
+
+	if node.Type != "dir" {
+		c.change(itemPath, modifier)
+		return nil
+	}
+
+	c.change(itemPath+"/", modifier)
+	return c.collectDir(ctx, itemPath, *node.Subtree, modifier, stat, blobs)
+}
@@ -0,0 +1,177 @@
+package diff_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rubiojr/rapi/archiver"
+	"github.com/rubiojr/rapi/diff"
+	"github.com/rubiojr/rapi/internal/fs"
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func snapshot(t *testing.T, repo restic.Repository, dir archiver.TestDir) *restic.Snapshot {
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	archiver.TestCreateFiles(t, tempdir, dir)
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := archiver.New(repo, fs.Track{FS: fs.Local{}}, archiver.Options{})
+	sn, _, err := arch.Snapshot(context.TODO(), []string{"."}, archiver.SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+	return sn
+}
+
+func TestSnapshots(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	sn1 := snapshot(t, repo, archiver.TestDir{
+		"modified": archiver.TestFile{Content: "old content"},
+		"removed":  archiver.TestFile{Content: "removed content"},
+		"same": archiver.TestDir{
+			"file": archiver.TestFile{Content: "same content"},
+		},
+		"subdir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "subdir content"},
+		},
+		"type": archiver.TestDir{
+			"file": archiver.TestFile{Content: "type content"},
+		},
+	})
+
+	sn2 := snapshot(t, repo, archiver.TestDir{
+		"modified": archiver.TestFile{Content: "new content"},
+		"added":    archiver.TestFile{Content: "added content"},
+		"same": archiver.TestDir{
+			"file": archiver.TestFile{Content: "same content"},
+		},
+		"subdir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "subdir content"},
+			"new": archiver.TestDir{
+				"file": archiver.TestFile{Content: "new subdir content"},
+			},
+		},
+		"type": archiver.TestSymlink{Target: "modified"},
+	})
+
+	var changes []diff.Change
+	stats, err := diff.Snapshots(context.TODO(), repo, sn1, sn2, diff.Options{
+		Change: func(c diff.Change) {
+			changes = append(changes, c)
+		},
+	})
+	rtest.OK(t, err)
+
+	rtest.Equals(t, []diff.Change{
+		{Path: "/added", Modifier: diff.Added},
+		{Path: "/modified", Modifier: diff.Modified},
+		{Path: "/removed", Modifier: diff.Removed},
+		{Path: "/subdir/new/", Modifier: diff.Added},
+		{Path: "/subdir/new/file", Modifier: diff.Added},
+		{Path: "/type", Modifier: diff.TypeChanged},
+		{Path: "/type/file", Modifier: diff.Removed},
+	}, changes)
+
+	rtest.Equals(t, 1, stats.ChangedFiles)
+
+	rtest.Equals(t, 2, stats.Added.Files)
+	rtest.Equals(t, 1, stats.Added.Dirs)
+	rtest.Equals(t, 3, stats.Added.DataBlobs)
+	// the root, same, subdir and subdir/new trees, same changed as the
+	// inodes of the files differ
+	rtest.Equals(t, 4, stats.Added.TreeBlobs)
+	dataBytes := uint64(len("new content") + len("added content") + len("new subdir content"))
+	rtest.Assert(t, stats.Added.Bytes > dataBytes,
+		"added bytes %d do not include the trees and %d bytes of data", stats.Added.Bytes, dataBytes)
+
+	rtest.Equals(t, 2, stats.Removed.Files)
+	rtest.Equals(t, 0, stats.Removed.Dirs)
+	rtest.Equals(t, 3, stats.Removed.DataBlobs)
+	// the root, same, subdir and type trees
+	rtest.Equals(t, 4, stats.Removed.TreeBlobs)
+}
+
+func TestSnapshotsIdentical(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	dir := archiver.TestDir{
+		"file": archiver.TestFile{Content: "content"},
+		"dir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "more content"},
+		},
+	}
+
+	sn := snapshot(t, repo, dir)
+
+	var changes []diff.Change
+	stats, err := diff.Snapshots(context.TODO(), repo, sn, sn, diff.Options{
+		ShowMetadata: true,
+		Change: func(c diff.Change) {
+			changes = append(changes, c)
+		},
+	})
+	rtest.OK(t, err)
+
+	rtest.Equals(t, 0, len(changes))
+	rtest.Equals(t, diff.Stats{}, *stats)
+}
+
+func TestSnapshotsMetadata(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	tempdir, cleanupTemp := rtest.TempDir(t)
+	defer cleanupTemp()
+
+	archiver.TestCreateFiles(t, tempdir, archiver.TestDir{
+		"file": archiver.TestFile{Content: "content"},
+		"dir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "unchanged content"},
+		},
+	})
+
+	back := rtest.Chdir(t, tempdir)
+	defer back()
+
+	arch := archiver.New(repo, fs.Track{FS: fs.Local{}}, archiver.Options{})
+	sn1, _, err := arch.Snapshot(context.TODO(), []string{"."}, archiver.SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+
+	rtest.OK(t, os.Chmod(filepath.Join(tempdir, "file"), 0600))
+
+	sn2, _, err := arch.Snapshot(context.TODO(), []string{"."}, archiver.SnapshotOptions{Time: time.Now()})
+	rtest.OK(t, err)
+
+	for _, showMetadata := range []bool{false, true} {
+		var changes []diff.Change
+		stats, err := diff.Snapshots(context.TODO(), repo, sn1, sn2, diff.Options{
+			ShowMetadata: showMetadata,
+			Change: func(c diff.Change) {
+				changes = append(changes, c)
+			},
+		})
+		rtest.OK(t, err)
+		rtest.Equals(t, 0, stats.ChangedFiles)
+		rtest.Equals(t, 0, stats.Added.DataBlobs)
+		rtest.Equals(t, 0, stats.Removed.DataBlobs)
+		// only the root tree differs, dir is identical
+		rtest.Equals(t, 1, stats.Added.TreeBlobs)
+		rtest.Equals(t, 1, stats.Removed.TreeBlobs)
+
+		if showMetadata {
+			rtest.Equals(t, []diff.Change{{Path: "/file", Modifier: diff.Metadata}}, changes)
+		} else {
+			rtest.Equals(t, 0, len(changes))
+		}
+	}
+}
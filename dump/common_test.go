@@ -5,7 +5,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/rubiojr/rapi/internal/archiver"
+	"github.com/rubiojr/rapi/archiver"
 	"github.com/rubiojr/rapi/internal/fs"
 	"github.com/rubiojr/rapi/repository"
 	"github.com/rubiojr/rapi/restic"
@@ -0,0 +1,131 @@
+package dump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// Dump writes the item at itemPath in the snapshot with the given ID to w.
+// Files are written as they are, directories as an archive in format, "tar"
+// or "zip". An itemPath of "/" writes the whole snapshot as an archive.
+//
+// The index of repo must have been loaded with LoadIndex before, otherwise
+// the trees and data blobs of the snapshot are not found. Dump doesn't load
+// it, so that it can be called concurrently on the same repository.
+func Dump(ctx context.Context, repo restic.Repository, snapshotID restic.ID, itemPath string, format string, w io.Writer) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, repo, snapshotID)
+	if err != nil {
+		return errors.Wrapf(err, "loading snapshot %q failed", snapshotID.Str())
+	}
+
+	tree, err := repo.LoadTree(ctx, *sn.Tree)
+	if err != nil {
+		return errors.Wrapf(err, "loading tree for snapshot %q failed", snapshotID.Str())
+	}
+
+	return New(format, repo, w).DumpPath(ctx, tree, itemPath)
+}
+
+// CheckFormat returns an error if format is not an archive format supported
+// by the Dumper.
+func CheckFormat(format string) error {
+	switch format {
+	case "tar", "zip":
+		return nil
+	default:
+		return fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+// DumpPath writes the item at itemPath in tree to d's Writer, see Dump.
+func (d *Dumper) DumpPath(ctx context.Context, tree *restic.Tree, itemPath string) error {
+	node, err := FindNode(ctx, d.repo, tree, itemPath)
+	if err != nil {
+		return err
+	}
+
+	if node == nil {
+		return d.DumpTree(ctx, tree, "/")
+	}
+
+	return d.DumpNode(ctx, node)
+}
+
+// DumpNode writes the contents of a file node to d's Writer, or the subtree
+// of a directory node as an archive.
+func (d *Dumper) DumpNode(ctx context.Context, node *restic.Node) error {
+	switch {
+	case IsFile(node):
+		return d.WriteNode(ctx, node)
+	case IsDir(node):
+		subtree, err := d.repo.LoadTree(ctx, *node.Subtree)
+		if err != nil {
+			return errors.Wrapf(err, "cannot load subtree for %q", node.Path)
+		}
+		return d.DumpTree(ctx, subtree, node.Path)
+	default:
+		return fmt.Errorf("%q should be a file, but is a %q", node.Path, node.Type)
+	}
+}
+
+// FindNode returns the node at itemPath in tree, loading the subtrees from
+// repo on the way. The Path of the returned node is set to the absolute path
+// of the item. For "/", the root of the tree, it returns a nil node.
+func FindNode(ctx context.Context, repo restic.Repository, tree *restic.Tree, itemPath string) (*restic.Node, error) {
+	components := splitPath(path.Clean(path.Join("/", itemPath)))
+	if components[0] == "" {
+		return nil, nil
+	}
+
+	prefix := "/"
+	for {
+		item := path.Join(prefix, components[0])
+
+		var node *restic.Node
+		for _, n := range tree.Nodes {
+			if n.Name == components[0] {
+				node = n
+				break
+			}
+		}
+		if node == nil {
+			return nil, fmt.Errorf("path %q not found in snapshot", item)
+		}
+
+		if len(components) == 1 {
+			node.Path = item
+			return node, nil
+		}
+
+		if !IsDir(node) {
+			return nil, fmt.Errorf("%q should be a dir, but is a %q", item, node.Type)
+		}
+
+		subtree, err := repo.LoadTree(ctx, *node.Subtree)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load subtree for %q", item)
+		}
+
+		tree = subtree
+		prefix = item
+		components = components[1:]
+	}
+}
+
+func splitPath(p string) []string {
+	d, f := path.Split(p)
+	if d == "" || d == "/" {
+		return []string{f}
+	}
+	s := splitPath(path.Join("/", d))
+	return append(s, f)
+}
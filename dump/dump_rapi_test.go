@@ -0,0 +1,137 @@
+package dump
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/rubiojr/rapi/archiver"
+	"github.com/rubiojr/rapi/internal/fs"
+	rtest "github.com/rubiojr/rapi/internal/test"
+)
+
+func TestDump(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tmpdir, repo, cleanup := prepareTempdirRepoSrc(t, archiver.TestDir{
+		"file": archiver.TestFile{Content: "root file"},
+		"dir": archiver.TestDir{
+			"another": archiver.TestFile{Content: "another file"},
+			"subdir": archiver.TestDir{
+				"nested": archiver.TestFile{Content: "nested file"},
+			},
+		},
+		"link": archiver.TestSymlink{Target: "file"},
+	})
+	defer cleanup()
+
+	arch := archiver.New(repo, fs.Track{FS: fs.Local{}}, archiver.Options{})
+
+	back := rtest.Chdir(t, tmpdir)
+	defer back()
+
+	_, id, err := arch.Snapshot(ctx, []string{"."}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	t.Run("file", func(t *testing.T) {
+		for _, test := range []struct {
+			path    string
+			content string
+		}{
+			{"/file", "root file"},
+			{"file", "root file"},
+			{"/dir/another", "another file"},
+			{"/dir/subdir/nested", "nested file"},
+		} {
+			var buf bytes.Buffer
+			rtest.OK(t, Dump(ctx, repo, id, test.path, "tar", &buf))
+			rtest.Equals(t, test.content, buf.String())
+		}
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		var buf bytes.Buffer
+		rtest.OK(t, Dump(ctx, repo, id, "/dir", "tar", &buf))
+		rtest.Equals(t, []string{"dir/another", "dir/subdir/", "dir/subdir/nested"}, tarNames(t, &buf))
+	})
+
+	t.Run("root", func(t *testing.T) {
+		var buf bytes.Buffer
+		rtest.OK(t, Dump(ctx, repo, id, "/", "tar", &buf))
+		rtest.Equals(t, []string{"dir/", "dir/another", "dir/subdir/", "dir/subdir/nested", "file", "link"}, tarNames(t, &buf))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, test := range []struct {
+			path   string
+			format string
+		}{
+			{"/missing", "tar"},
+			{"/file/foo", "tar"},
+			{"/link", "tar"},
+			{"/dir", "rar"},
+		} {
+			var buf bytes.Buffer
+			err := Dump(ctx, repo, id, test.path, test.format, &buf)
+			rtest.Assert(t, err != nil, "expected error dumping %q as %v", test.path, test.format)
+			rtest.Equals(t, 0, buf.Len())
+		}
+	})
+}
+
+func TestFindNode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tmpdir, repo, cleanup := prepareTempdirRepoSrc(t, archiver.TestDir{
+		"dir": archiver.TestDir{
+			"file": archiver.TestFile{Content: "file"},
+		},
+	})
+	defer cleanup()
+
+	arch := archiver.New(repo, fs.Track{FS: fs.Local{}}, archiver.Options{})
+
+	back := rtest.Chdir(t, tmpdir)
+	defer back()
+
+	sn, _, err := arch.Snapshot(ctx, []string{"."}, archiver.SnapshotOptions{})
+	rtest.OK(t, err)
+
+	tree, err := repo.LoadTree(ctx, *sn.Tree)
+	rtest.OK(t, err)
+
+	node, err := FindNode(ctx, repo, tree, "/")
+	rtest.OK(t, err)
+	rtest.Assert(t, node == nil, "expected nil node for the root, got %v", node)
+
+	node, err = FindNode(ctx, repo, tree, "/dir/file/")
+	rtest.OK(t, err)
+	rtest.Equals(t, "file", node.Type)
+	rtest.Equals(t, "/dir/file", node.Path)
+
+	node, err = FindNode(ctx, repo, tree, "dir")
+	rtest.OK(t, err)
+	rtest.Equals(t, "dir", node.Type)
+	rtest.Equals(t, "/dir", node.Path)
+}
+
+func tarNames(t *testing.T, rd io.Reader) []string {
+	var names []string
+	tr := tar.NewReader(rd)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
@@ -20,6 +20,7 @@ require (
 	github.com/gopherjs/gopherjs v0.0.0-20200217142428-fce0ec30dd00 // indirect
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/juju/ratelimit v1.0.1
+	github.com/klauspost/compress v1.13.5
 	github.com/kr/text v0.2.0 // indirect
 	github.com/kurin/blazer v0.5.3
 	github.com/minio/minio-go/v7 v7.0.15
@@ -0,0 +1,37 @@
+@test "rapi backup prints help" {
+  run ./rapi backup --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi backup creates a snapshot" {
+  ./script/init-test-repo
+  run ./rapi backup integration/fixtures
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "snapshot " ]]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+  restic check
+}
+
+@test "rapi backup excludes files" {
+  ./script/init-test-repo
+  run ./rapi backup --exclude hello --tag foo integration/fixtures
+  [ "$status" -eq 0 ]
+  run restic ls latest
+  [[ ! "$output" =~ "hello" ]]
+  [ "$(restic snapshots --json --tag foo | jq length)" -eq 1 ]
+}
+
+@test "rapi backup --dry-run does not save a snapshot" {
+  ./script/init-test-repo
+  run ./rapi backup --dry-run integration/fixtures
+  [ "$status" -eq 0 ]
+  [ "$(restic snapshots --json | jq length)" -eq 0 ]
+}
+
+@test "rapi backup --json prints a summary" {
+  ./script/init-test-repo
+  run ./rapi backup --json integration/fixtures
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | tail -n1 | jq -r .message_type)" = "summary" ]
+}
@@ -0,0 +1,16 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi cat pack prints a pack file" {
+  pack=$(restic list packs --no-lock | head -1)
+  [ "$(./rapi cat pack "$pack" | sha256sum | cut -d' ' -f1)" = "$pack" ]
+  [ "$(./rapi cat pack "${pack:0:8}" | sha256sum | cut -d' ' -f1)" = "$pack" ]
+}
+
+@test "rapi cat pack fails for unknown packs" {
+  run ./rapi cat pack 00000000
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "could not find pack" ]]
+}
@@ -0,0 +1,23 @@
+@test "rapi check prints help" {
+  run ./rapi check --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi check finds no errors" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi check --read-data
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "no errors were found" ]]
+}
+
+@test "rapi check --json reports missing packs" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  rm -f "$(find "$RESTIC_REPOSITORY/data" -type f | head -n1)"
+  run ./rapi check --json
+  [ "$status" -eq 1 ]
+  report=$(./rapi check --json 2>/dev/null || true)
+  [ "$(echo "$report" | jq -r '.errors[] | select(.type == "pack") | .message' | grep -c 'does not exist')" -eq 1 ]
+}
@@ -0,0 +1,44 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  dst="$BATS_TMPDIR/rapi-copy"
+  rm -rf "$dst"
+  RESTIC_REPOSITORY="$dst" RESTIC_PASSWORD=other restic init > /dev/null
+}
+
+teardown() {
+  rm -rf "$dst"
+}
+
+@test "rapi copy prints help" {
+  run ./rapi copy --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--from-repo" ]]
+}
+
+@test "rapi copy requires a source repository" {
+  run ./rapi copy
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "please specify a source repository" ]]
+}
+
+@test "rapi copy copies snapshots to another repository" {
+  src="$RESTIC_REPOSITORY"
+  snap=$(restic snapshots --json | jq -r '.[0].id')
+  run ./rapi -r "$dst" -p other copy --from-repo "$src" --from-password test
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "copied to snapshot" ]]
+  original=$(RESTIC_REPOSITORY="$dst" RESTIC_PASSWORD=other restic snapshots --json | jq -r '.[0].original')
+  [ "$original" = "$snap" ]
+  RESTIC_REPOSITORY="$dst" RESTIC_PASSWORD=other restic check
+  RESTIC_REPOSITORY="$dst" RESTIC_PASSWORD=other restic dump latest /integration/fixtures/hello
+}
+
+@test "rapi copy skips snapshots already copied" {
+  src="$RESTIC_REPOSITORY"
+  ./rapi -r "$dst" -p other copy --from-repo "$src" --from-password test
+  run ./rapi -r "$dst" -p other copy --from-repo "$src" --from-password test
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "skipping, already copied" ]]
+  [ "$(RESTIC_REPOSITORY="$dst" RESTIC_PASSWORD=other restic snapshots --json | jq length)" -eq 1 ]
+}
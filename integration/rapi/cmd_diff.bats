@@ -0,0 +1,45 @@
+setup() {
+  ./script/init-test-repo
+  src="$BATS_TMPDIR/rapi-diff"
+  rm -rf "$src"
+  cp -r integration/fixtures "$src"
+  restic backup "$src" > /dev/null
+  echo changed > "$src/hello"
+  echo new > "$src/new"
+  rm -rf "$src/mytree2"
+  restic backup "$src" > /dev/null
+  snap1=$(restic snapshots --json | jq -r '.[0].id')
+  snap2=$(restic snapshots --json | jq -r '.[1].id')
+}
+
+teardown() {
+  rm -rf "$src"
+}
+
+@test "rapi diff prints help" {
+  run ./rapi diff --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi diff lists the changes" {
+  run ./rapi diff "$snap1" "$snap2"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "M    $src/hello" ]]
+  [[ "$output" =~ "+    $src/new" ]]
+  [[ "$output" =~ "-    $src/mytree2/" ]]
+  [[ "$output" =~ "Files:           1 new," ]]
+}
+
+@test "rapi diff --json prints the changes and statistics" {
+  run ./rapi diff --json "$snap1" "$snap2"
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | jq -r .statistics.changed_files)" -eq 1 ]
+  [ "$(echo "$output" | jq -r '.changes[] | select(.modifier == "+") | .path')" == "$src/new" ]
+}
+
+@test "rapi diff requires two snapshots" {
+  run ./rapi diff "$snap1"
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "specify two snapshot IDs" ]]
+}
@@ -0,0 +1,48 @@
+@test "rapi dump prints help" {
+  run ./rapi dump --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi dump prints a file" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi dump latest /integration/fixtures/hello
+  [ "$status" -eq 0 ]
+  [ "$output" == "$(cat integration/fixtures/hello)" ]
+}
+
+@test "rapi dump writes a directory as tar" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run bash -c "./rapi dump latest /integration/fixtures | tar t"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "integration/fixtures/hello" ]]
+  [[ "$output" =~ "integration/fixtures/mytree2/empty" ]]
+}
+
+@test "rapi dump writes a directory as zip" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  out=$(mktemp)
+  ./rapi dump --archive zip latest /integration/fixtures > "$out"
+  run unzip -l "$out"
+  rm -f "$out"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "integration/fixtures/hello" ]]
+}
+
+@test "rapi dump fails with missing paths" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi dump latest /integration/missing
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "not found in snapshot" ]]
+}
+
+@test "rapi dump rejects unknown archive formats" {
+  ./script/init-test-repo
+  run ./rapi dump --archive rar latest /
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "unknown archive format" ]]
+}
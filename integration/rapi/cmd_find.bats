@@ -0,0 +1,47 @@
+@test "rapi find prints help" {
+  run ./rapi find --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi find finds files in all snapshots" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  restic backup integration/fixtures > /dev/null
+  run ./rapi find hello
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | grep -c "Found matching entries")" -eq 2 ]
+  [[ "$output" =~ "/integration/fixtures/hello" ]]
+}
+
+@test "rapi find supports ** patterns" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi find '/integration/**/empty'
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "/integration/fixtures/mytree2/empty" ]]
+}
+
+@test "rapi find honors --oldest" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi find --oldest 2999-01-01 hello
+  [ "$status" -eq 0 ]
+  [ -z "$output" ]
+}
+
+@test "rapi find --blob finds files by blob ID" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  blob=$(restic list blobs | grep ^data | head -1 | cut -d' ' -f2)
+  run ./rapi find --blob "$blob"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "Found blob $blob" ]]
+}
+
+@test "rapi find rejects several ID types" {
+  ./script/init-test-repo
+  run ./rapi find --blob --tree 12345678
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "cannot have several ID types" ]]
+}
@@ -0,0 +1,47 @@
+@test "rapi forget prints help" {
+  run ./rapi forget --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi forget removes snapshots according to the policy" {
+  ./script/init-test-repo
+  restic backup --tag first integration/fixtures > /dev/null
+  restic backup integration/fixtures > /dev/null
+  restic backup integration/fixtures > /dev/null
+  run ./rapi forget --keep-last 1 --keep-tag first
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "remove 1 snapshots" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 2 ]
+  [ "$(restic snapshots --json --tag first | jq length)" -eq 1 ]
+}
+
+@test "rapi forget --dry-run does not remove snapshots" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  restic backup integration/fixtures > /dev/null
+  run ./rapi forget --dry-run --keep-last 1
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "would have removed" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 2 ]
+}
+
+@test "rapi forget requires a policy" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi forget
+  [ "$status" -eq 1 ]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+}
+
+@test "rapi forget --prune removes unused data" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  restic backup --tag second integration/rapi > /dev/null
+  id=$(restic snapshots --json --tag second | jq -r '.[0].id')
+  run ./rapi forget --prune "$id"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "done" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+  restic check
+}
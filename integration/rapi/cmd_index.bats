@@ -0,0 +1,43 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi index rebuild prints help" {
+  run ./rapi index rebuild --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--read-all-packs" ]]
+}
+
+@test "rapi index rebuild reuses the index" {
+  run ./rapi index rebuild
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "done" ]]
+  [[ ! "$output" =~ "adding pack file" ]]
+  restic check
+}
+
+@test "rapi index rebuild adds packs missing in the index" {
+  rm -f "$RESTIC_REPOSITORY"/index/*
+  run ./rapi index rebuild
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "adding pack file to index" ]]
+  restic check
+}
+
+@test "rapi index rebuild removes missing packs" {
+  rm -f "$(find "$RESTIC_REPOSITORY/data" -type f | head -n1)"
+  run ./rapi index rebuild
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "removing not found pack file" ]]
+  run restic check
+  [[ ! "$output" =~ "not referenced in any index" ]]
+}
+
+@test "rapi index rebuild --read-all-packs replaces the index" {
+  old=$(ls "$RESTIC_REPOSITORY"/index)
+  run ./rapi index rebuild --read-all-packs
+  [ "$status" -eq 0 ]
+  [ "$(ls "$RESTIC_REPOSITORY"/index)" != "$old" ]
+  restic check
+}
@@ -0,0 +1,43 @@
+setup() {
+  ./script/init-test-repo
+  dst="$BATS_TMPDIR/rapi-init"
+  rm -rf "$dst"
+}
+
+teardown() {
+  rm -rf "$dst"
+}
+
+@test "rapi init prints help" {
+  run ./rapi init --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--copy-chunker-params" ]]
+}
+
+@test "rapi init creates a repository" {
+  run ./rapi -r "$dst" init
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "created restic repository" ]]
+  [ "$(RESTIC_REPOSITORY="$dst" restic cat config | jq .version)" -eq 2 ]
+  RESTIC_REPOSITORY="$dst" restic check
+}
+
+@test "rapi init refuses to overwrite a repository" {
+  ./rapi -r "$dst" init
+  run ./rapi -r "$dst" init
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "config file already exists" ]]
+}
+
+@test "rapi init --repository-version selects the version" {
+  ./rapi -r "$dst" init --repository-version 1
+  [ "$(RESTIC_REPOSITORY="$dst" restic cat config | jq .version)" -eq 1 ]
+  run ./rapi -r "$dst-3" init --repository-version 3
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "unsupported repository version" ]]
+}
+
+@test "rapi init --copy-chunker-params copies the chunker polynomial" {
+  ./rapi -r "$dst" init --copy-chunker-params --from-repo "$RESTIC_REPOSITORY" --from-password "$RESTIC_PASSWORD"
+  [ "$(RESTIC_REPOSITORY="$dst" restic cat config | jq -r .chunker_polynomial)" = "$(restic cat config | jq -r .chunker_polynomial)" ]
+}
@@ -0,0 +1,54 @@
+setup() {
+  ./script/init-test-repo
+  pwfile="$BATS_TMPDIR/rapi-key-password"
+  echo newpassword > "$pwfile"
+}
+
+teardown() {
+  rm -f "$pwfile"
+}
+
+@test "rapi key prints help" {
+  run ./rapi key
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi key list marks the current key" {
+  run ./rapi key list
+  [ "$status" -eq 0 ]
+  key=$(restic key list --json | jq -r '.[0].id')
+  [[ "$output" =~ "*$key" ]]
+}
+
+@test "rapi key add adds a key" {
+  run ./rapi key add --new-password-file "$pwfile" --user bob --host example
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "saved new key as" ]]
+  [ "$(./rapi key list --json | jq length)" -eq 2 ]
+  RESTIC_PASSWORD=newpassword ./rapi key list | grep bob
+}
+
+@test "rapi key remove removes a key" {
+  ./rapi key add --new-password-file "$pwfile"
+  other=$(./rapi key list --json | jq -r '.[] | select(.current | not) | .id')
+  run ./rapi key remove "$other"
+  [ "$status" -eq 0 ]
+  [ "$(./rapi key list --json | jq length)" -eq 1 ]
+}
+
+@test "rapi key remove refuses to remove the current key" {
+  current=$(./rapi key list --json | jq -r '.[] | select(.current) | .id')
+  run ./rapi key remove "$current"
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "refusing to remove key currently used" ]]
+}
+
+@test "rapi key passwd changes the password" {
+  run ./rapi key passwd --new-password-file "$pwfile"
+  [ "$status" -eq 0 ]
+  run ./rapi key list
+  [ "$status" -eq 1 ]
+  RESTIC_PASSWORD=newpassword ./rapi key list
+  [ "$(RESTIC_PASSWORD=newpassword ./rapi key list --json | jq length)" -eq 1 ]
+}
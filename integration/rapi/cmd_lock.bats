@@ -0,0 +1,94 @@
+# hold_lock runs a command which holds a lock while it waits for stdin. pid
+# is the PID of the command, feeder the PID of the process keeping its stdin
+# open.
+hold_lock() {
+  fifo="$BATS_TMPDIR/rapi-lock-stdin"
+  rm -f "$fifo"
+  mkfifo "$fifo"
+  "$@" < "$fifo" > /dev/null 2>&1 &
+  pid=$!
+  sleep 60 > "$fifo" &
+  feeder=$!
+  sleep 2
+}
+
+setup() {
+  ./script/init-test-repo
+  # leave a stale lock behind
+  hold_lock restic backup --stdin
+  kill -9 $pid
+  kill $feeder
+}
+
+teardown() {
+  kill -9 $pid $feeder 2> /dev/null || true
+  rm -f "$fifo"
+}
+
+@test "rapi lock prints help" {
+  run ./rapi lock
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi lock list lists the locks" {
+  run ./rapi lock list
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "$pid" ]]
+  [ "$(./rapi lock list --json | jq length)" -eq 1 ]
+  [ "$(./rapi lock list --json | jq -r '.[0].stale')" = "true" ]
+}
+
+@test "rapi cat lock prints a lock" {
+  id=$(./rapi lock list --json | jq -r '.[0].id')
+  [ "$(./rapi cat lock "$id" | jq -r .pid)" -eq "$pid" ]
+}
+
+@test "rapi unlock removes stale locks" {
+  run ./rapi unlock
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "successfully removed locks" ]]
+  [ "$(./rapi lock list --json | jq length)" -eq 0 ]
+  [ "$(restic list locks --no-lock | wc -l)" -eq 0 ]
+}
+
+@test "rapi unlock --remove-all removes all locks" {
+  run ./rapi unlock --remove-all
+  [ "$status" -eq 0 ]
+  [ "$(./rapi lock list --json | jq length)" -eq 0 ]
+}
+
+@test "rapi commands modifying the repository lock it" {
+  run ./rapi forget --keep-last 1
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "repository is already locked" ]]
+  run ./rapi --no-lock forget --keep-last 1 --dry-run
+  [ "$status" -eq 0 ]
+  ./rapi unlock
+  run ./rapi forget --keep-last 1
+  [ "$status" -eq 0 ]
+  [ "$(./rapi lock list --json | jq length)" -eq 0 ]
+}
+
+@test "rapi commands reading the repository take a shared lock" {
+  ./rapi unlock
+  hold_lock ./rapi key passwd
+  [ "$(./rapi lock list --json | jq -r '.[0].exclusive')" = "true" ]
+  for cmd in snapshots "ls latest" "stats"; do
+    run ./rapi $cmd
+    [ "$status" -eq 1 ]
+    [[ "$output" =~ "repository is already locked exclusively" ]]
+  done
+  run ./rapi --no-lock snapshots
+  [ "$status" -eq 0 ]
+}
+
+@test "rapi check takes an exclusive lock" {
+  ./rapi unlock
+  hold_lock restic backup --stdin
+  run ./rapi check
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "repository is already locked by" ]]
+  run ./rapi --no-lock check
+  [ "$status" -eq 0 ]
+}
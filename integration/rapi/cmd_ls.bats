@@ -0,0 +1,40 @@
+@test "rapi ls prints help" {
+  run ./rapi ls --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi ls lists the snapshot contents" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi ls latest
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "/integration/fixtures/hello" ]]
+  [[ "$output" =~ "/integration/fixtures/mytree2/empty" ]]
+}
+
+@test "rapi ls lists a directory" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi ls --long latest /integration/fixtures
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "/integration/fixtures/hello" ]]
+  [[ "$output" =~ "-rw" ]]
+  [[ ! "$output" =~ "/integration/fixtures/mytree2/empty" ]]
+}
+
+@test "rapi ls --json prints one object per line" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi ls --json latest /integration/fixtures/hello
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | head -1 | jq -r .struct_type)" == "snapshot" ]
+  [ "$(echo "$output" | tail -1 | jq -r .path)" == "/integration/fixtures/hello" ]
+}
+
+@test "rapi ls requires absolute paths" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi ls latest integration
+  [ "$status" -eq 1 ]
+}
@@ -0,0 +1,31 @@
+@test "rapi migrate prints help" {
+  run ./rapi migrate --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi migrate lists available migrations" {
+  ./script/init-test-repo
+  run ./rapi migrate
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "upgrade_repo_v2" ]]
+}
+
+@test "rapi migrate upgrade_repo_v2 upgrades the repository" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  run ./rapi migrate upgrade_repo_v2
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "migration upgrade_repo_v2: success" ]]
+  run ./rapi repository info
+  [[ "$output" =~ "Repository version:  2" ]]
+  ./rapi backup integration/rapi > /dev/null
+  ./rapi check --read-data
+}
+
+@test "rapi migrate fails with unknown migrations" {
+  ./script/init-test-repo
+  run ./rapi migrate foobar
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "unknown migration foobar" ]]
+}
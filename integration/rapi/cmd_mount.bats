@@ -0,0 +1,39 @@
+@test "rapi mount prints help" {
+  run ./rapi mount --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi mount requires a mountpoint" {
+  ./script/init-test-repo
+  run ./rapi mount
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "wrong number of parameters" ]]
+}
+
+@test "rapi mount rejects snapshot templates with slashes" {
+  ./script/init-test-repo
+  run ./rapi mount --snapshot-template "2006/01/02" "$BATS_TMPDIR/mnt"
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "snapshot template string contains a slash" ]]
+}
+
+@test "rapi mount serves the repository snapshots" {
+  if ! command -v fusermount > /dev/null; then
+    skip "fusermount not available"
+  fi
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  mnt="$BATS_TMPDIR/rapi-mount"
+  ./rapi mount "$mnt" &
+  pid=$!
+  for i in $(seq 1 20); do
+    [ -d "$mnt/snapshots/latest" ] && break
+    sleep 0.5
+  done
+  run ls "$mnt/snapshots/latest"
+  kill -INT $pid
+  wait $pid
+  [ "$status" -eq 0 ]
+  [ -n "$output" ]
+}
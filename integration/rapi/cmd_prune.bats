@@ -0,0 +1,36 @@
+@test "rapi prune prints help" {
+  run ./rapi prune --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi prune removes unused packs" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  restic backup integration/rapi > /dev/null
+  restic forget latest > /dev/null
+  packs=$(find "$RESTIC_REPOSITORY/data" -type f | wc -l)
+  run ./rapi prune
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "done" ]]
+  [ "$(find "$RESTIC_REPOSITORY/data" -type f | wc -l)" -lt "$packs" ]
+  restic check --read-data
+}
+
+@test "rapi prune --dry-run does not modify the repository" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  restic backup integration/rapi > /dev/null
+  restic forget latest > /dev/null
+  packs=$(find "$RESTIC_REPOSITORY/data" -type f | wc -l)
+  run ./rapi prune --dry-run
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "dry run" ]]
+  [ "$(find "$RESTIC_REPOSITORY/data" -type f | wc -l)" -eq "$packs" ]
+}
+
+@test "rapi prune rejects invalid --max-unused values" {
+  ./script/init-test-repo
+  run ./rapi prune --max-unused 100%
+  [ "$status" -eq 1 ]
+}
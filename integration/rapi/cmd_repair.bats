@@ -0,0 +1,43 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi repair snapshots prints help" {
+  run ./rapi repair snapshots --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--forget" ]]
+}
+
+@test "rapi repair snapshots finds no damage" {
+  run ./rapi repair snapshots
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "no damage found" ]]
+  [[ "$output" =~ "no snapshots were modified" ]]
+}
+
+@test "rapi repair snapshots removes missing files" {
+  index=$(ls "$RESTIC_REPOSITORY/index" | head -n1)
+  pack=$(./rapi cat index "$index" | jq -r '[.packs[] | select(.blobs[0].type == "data")][0].id')
+  rm -f "$RESTIC_REPOSITORY/data/${pack:0:2}/$pack"
+  ./rapi index rebuild > /dev/null
+  run ./rapi repair snapshots
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "is missing, removed" ]]
+  [[ "$output" =~ "repaired 1 snapshots" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 2 ]
+  [ "$(restic snapshots --json --tag repaired | jq -r '.[0].original')" != "null" ]
+}
+
+@test "rapi repair snapshots --forget removes the damaged snapshots" {
+  index=$(ls "$RESTIC_REPOSITORY/index" | head -n1)
+  pack=$(./rapi cat index "$index" | jq -r '[.packs[] | select(.blobs[0].type == "data")][0].id')
+  rm -f "$RESTIC_REPOSITORY/data/${pack:0:2}/$pack"
+  ./rapi index rebuild > /dev/null
+  run ./rapi repair snapshots --forget
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "the damaged snapshots were removed" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+  [ "$(restic snapshots --json | jq -r '.[0].tags[0]')" = "repaired" ]
+  restic check
+}
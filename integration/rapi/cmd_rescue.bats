@@ -0,0 +1,69 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  target="$BATS_TMPDIR/rapi-rescue"
+  rm -rf "$target"
+}
+
+teardown() {
+  rm -rf "$target"
+}
+
+@test "rapi rescue recover does nothing without lost snapshots" {
+  run ./rapi rescue recover
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "found 0 unreferenced roots" ]]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+}
+
+@test "rapi rescue recover saves the lost trees in a new snapshot" {
+  tree=$(restic snapshots --json | jq -r '.[0].tree')
+  rm -f "$RESTIC_REPOSITORY"/snapshots/*
+  run ./rapi rescue recover
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "found 1 unreferenced roots" ]]
+  [ "$(restic snapshots --json | jq -r '.[0].tags[0]')" = "recovered" ]
+  restic check
+  restic restore latest --target "$target"
+  diff -r integration/fixtures "$target/${tree:0:8}$PWD/integration/fixtures"
+}
+
+@test "rapi rescue carve extracts the files from the data directory" {
+  ./rapi cat masterkey > "$BATS_TMPDIR/rapi-masterkey"
+  rm -rf "$RESTIC_REPOSITORY"/{index,snapshots,keys,config}
+  run ./rapi rescue carve --key-file "$BATS_TMPDIR/rapi-masterkey" --target "$target" "$RESTIC_REPOSITORY/data"
+  rm -f "$BATS_TMPDIR/rapi-masterkey"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "Incomplete:   0" ]]
+  diff -r integration/fixtures "$target"/*"$PWD/integration/fixtures"
+}
+
+@test "rapi rescue carve writes raw chunks when trees are missing" {
+  key=$(ls "$RESTIC_REPOSITORY"/keys | head -1)
+  index=$(restic list index --no-lock | head -1)
+  for pack in $(restic cat index "$index" --no-lock | jq -r '.packs[] | select(.blobs[0].type == "tree") | .id'); do
+    rm -f "$RESTIC_REPOSITORY"/data/*/"$pack"
+  done
+  run ./rapi rescue carve --key-file "$RESTIC_REPOSITORY/keys/$key" --target "$target" "$RESTIC_REPOSITORY/data"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "Root trees:   0" ]]
+  [ "$(ls "$target/chunks" | wc -l)" -gt 0 ]
+  cat "$target"/chunks/* | grep -q "$(cat integration/fixtures/hello)"
+}
+
+@test "rapi rescue carve fills the blobs which cannot be decrypted with zeros" {
+  index=$(ls "$RESTIC_REPOSITORY/index" | head -n1)
+  pack=$(./rapi cat index "$index" | jq -r '[.packs[] | select(.blobs[0].type == "data")][0].id')
+  ./rapi cat masterkey > "$BATS_TMPDIR/rapi-masterkey"
+  file="$RESTIC_REPOSITORY/data/${pack:0:2}/$pack"
+  chmod u+w "$file"
+  printf 'damaged' | dd of="$file" bs=1 seek=20 conv=notrunc 2>/dev/null
+  run ./rapi rescue carve --key-file "$BATS_TMPDIR/rapi-masterkey" --target "$target" "$RESTIC_REPOSITORY/data"
+  rm -f "$BATS_TMPDIR/rapi-masterkey"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "filled with zeros" ]]
+  [[ "$output" =~ "Incomplete:   1" ]]
+  # the damaged file keeps its size
+  diff <(cd integration/fixtures && find . -type f -printf '%s %p\n' | sort) \
+    <(cd "$target"/*"$PWD/integration/fixtures" && find . -type f -printf '%s %p\n' | sort)
+}
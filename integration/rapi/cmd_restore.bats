@@ -0,0 +1,54 @@
+@test "rapi restore prints help" {
+  run ./rapi restore --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "USAGE:" ]]
+}
+
+@test "rapi restore restores the latest snapshot" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  target=$(mktemp -d)
+  run ./rapi restore --target "$target" --verify latest
+  [ "$status" -eq 0 ]
+  diff -r integration/fixtures "$target/integration/fixtures"
+  rm -rf "$target"
+}
+
+@test "rapi restore honors excludes" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  target=$(mktemp -d)
+  run ./rapi restore --target "$target" --exclude hello latest
+  [ "$status" -eq 0 ]
+  [ ! -e "$target/integration/fixtures/hello" ]
+  [ -d "$target/integration/fixtures/mytree2" ]
+  rm -rf "$target"
+}
+
+@test "rapi restore --allow-damage zero-fills damaged blobs" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  index=$(ls "$RESTIC_REPOSITORY/index" | head -n1)
+  pack=$(./rapi cat index "$index" | jq -r '[.packs[] | select(.blobs[0].type == "data")][0].id')
+  file="$RESTIC_REPOSITORY/data/${pack:0:2}/$pack"
+  chmod u+w "$file"
+  printf 'damaged' | dd of="$file" bs=1 seek=20 conv=notrunc 2>/dev/null
+  target=$(mktemp -d)
+  run ./rapi restore --target "$target" latest
+  [ "$status" -eq 1 ]
+  [[ ! "$output" =~ "\"blob_id\"" ]]
+  rm -rf "$target"/*
+  run ./rapi restore --target "$target" --allow-damage latest
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "\"blob_id\"" ]]
+  [[ "$output" =~ "ranges of the restored files are damaged" ]]
+  # damaged files are restored with their original size
+  diff <(cd integration/fixtures && find . -type f -printf '%s %p\n' | sort) \
+    <(cd "$target/integration/fixtures" && find . -type f -printf '%s %p\n' | sort)
+  rm -rf "$target"/*
+  run ./rapi restore --target "$target" --allow-damage --damage-report "$target.json" latest
+  [ "$status" -eq 1 ]
+  [[ ! "$output" =~ "\"blob_id\"" ]]
+  [ "$(jq length "$target.json")" -ge 1 ]
+  rm -rf "$target" "$target.json"
+}
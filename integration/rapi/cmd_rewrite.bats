@@ -0,0 +1,40 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi rewrite prints help" {
+  run ./rapi rewrite --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--exclude" ]]
+}
+
+@test "rapi rewrite needs --all or snapshot IDs" {
+  run ./rapi rewrite --exclude hello
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "either --all or the snapshot IDs must be given" ]]
+}
+
+@test "rapi rewrite --dry-run lists the items to remove" {
+  old=$(restic snapshots --json | jq -r '.[0].id')
+  run ./rapi rewrite --dry-run --exclude hello --exclude mytree2 --all
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "would remove /integration/fixtures/hello" ]]
+  [[ "$output" =~ "would remove /integration/fixtures/mytree2" ]]
+  [[ "$output" =~ "would rewrite 1 snapshots" ]]
+  [ "$(restic snapshots --json | jq -r '.[0].id')" = "$old" ]
+}
+
+@test "rapi rewrite removes files from snapshots" {
+  old=$(restic snapshots --json | jq -r '.[0].id')
+  run ./rapi rewrite --exclude hello "${old:0:8}"
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "removing /integration/fixtures/hello" ]]
+  [ "$(restic snapshots --json | jq -r '.[0].original')" = "$old" ]
+  run restic ls latest
+  [[ ! "$output" =~ "hello" ]]
+  [[ "$output" =~ "mytree2" ]]
+  restic check
+  run ./rapi rewrite --exclude hello --all
+  [[ "$output" =~ "no snapshots were modified" ]]
+}
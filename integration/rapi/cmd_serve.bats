@@ -0,0 +1,82 @@
+setup() {
+  data="$BATS_TMPDIR/rapi-serve"
+  rm -rf "$data"
+  mkdir -p "$data"
+}
+
+teardown() {
+  if [ -n "$pid" ]; then
+    kill -INT $pid
+    wait $pid || true
+  fi
+  rm -rf "$data"
+}
+
+wait_for_server() {
+  for i in $(seq 1 20); do
+    curl -s -o /dev/null "http://localhost:8123/" && return 0
+    sleep 0.5
+  done
+  return 1
+}
+
+@test "rapi serve prints help" {
+  run ./rapi serve --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--append-only" ]]
+}
+
+@test "rapi serve requires an htpasswd file" {
+  run ./rapi serve --path "$data"
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "use --no-auth to disable authentication" ]]
+}
+
+@test "rapi serve serves repositories to restic" {
+  ./rapi serve --path "$data" --listen localhost:8123 --no-auth --prometheus &
+  pid=$!
+  wait_for_server
+  export RESTIC_REPOSITORY=rest:http://localhost:8123/repo/
+  restic init
+  restic backup integration/fixtures
+  restic check
+  [ -d "$data/repo/data" ]
+  run curl -s http://localhost:8123/metrics
+  [[ "$output" =~ 'rest_server_blob_write_total{repo="repo",type="data",user=""}' ]]
+}
+
+@test "rapi serve --append-only refuses to remove data" {
+  ./rapi serve --path "$data" --listen localhost:8123 --no-auth --append-only &
+  pid=$!
+  wait_for_server
+  export RESTIC_REPOSITORY=rest:http://localhost:8123/repo/
+  restic init
+  restic backup integration/fixtures
+  snap=$(restic snapshots --json | jq -r '.[0].id')
+  run restic forget "$snap"
+  [ "$status" -ne 0 ]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+}
+
+@test "rapi serve serves the repository given with -r" {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+  ./rapi serve --listen localhost:8123 --no-auth &
+  pid=$!
+  wait_for_server
+  [ "$(restic -r rest:http://localhost:8123/ snapshots --json | jq length)" -eq 1 ]
+  restic -r rest:http://localhost:8123/ backup integration/fixtures
+  [ "$(restic snapshots --json | jq length)" -eq 2 ]
+  run curl -s -o /dev/null -w "%{http_code}" http://localhost:8123/other/keys/
+  [ "$output" = "404" ]
+}
+
+@test "rapi serve does not create missing repositories on upload" {
+  ./rapi serve --path "$data" --listen localhost:8123 --no-auth &
+  pid=$!
+  wait_for_server
+  run curl -s -o /dev/null -w "%{http_code}" --data-binary foobar \
+    http://localhost:8123/repo/data/c3ab8ff13720e8ad9047dd39466b3c8974e592c2fa383d4a3960714caef0c4f2
+  [ "$output" = "404" ]
+  [ ! -e "$data/repo" ]
+}
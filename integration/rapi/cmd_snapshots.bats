@@ -0,0 +1,45 @@
+setup() {
+  ./script/init-test-repo
+  restic backup --tag first integration/fixtures > /dev/null
+  restic backup --host other integration/fixtures > /dev/null
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi snapshots prints help" {
+  run ./rapi snapshots --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--group-by" ]]
+}
+
+@test "rapi snapshots lists all snapshots" {
+  run ./rapi snapshots
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "3 snapshots" ]]
+  [ "$(./rapi snapshots --json | jq length)" -eq 3 ]
+  [ "$(./rapi snapshots --json | jq -r '.[0].id')" = "$(restic snapshots --json | jq -r '.[0].id')" ]
+}
+
+@test "rapi snapshots filters snapshots" {
+  [ "$(./rapi snapshots --json --host other | jq length)" -eq 1 ]
+  [ "$(./rapi snapshots --json --tag first | jq -r '.[0].tags[0]')" = "first" ]
+}
+
+@test "rapi snapshots groups snapshots" {
+  run ./rapi snapshots --group-by host
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "snapshots for host [other]" ]]
+  [ "$(./rapi snapshots --json --group-by host | jq length)" -eq 2 ]
+  [ "$(./rapi snapshots --json --group-by host | jq '.[] | select(.group_key.hostname == "other") | .snapshots | length')" -eq 1 ]
+}
+
+@test "rapi snapshots --latest limits the snapshots of each group" {
+  last=$(restic snapshots --json | jq -r '.[2].id')
+  [ "$(./rapi snapshots --json --latest 1 | jq -r '.[0].id')" = "$last" ]
+  [ "$(./rapi snapshots --json --latest 1 --group-by host | jq '[.[].snapshots[]] | length')" -eq 2 ]
+}
+
+@test "rapi snapshots rejects unknown groups" {
+  run ./rapi snapshots --group-by foo
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "unknown grouping option" ]]
+}
@@ -0,0 +1,51 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi stats prints help" {
+  run ./rapi stats --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--mode" ]]
+}
+
+@test "rapi stats rejects unknown modes" {
+  run ./rapi stats --mode foo
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "unknown counting mode: foo" ]]
+}
+
+@test "rapi stats matches restic in all modes" {
+  for mode in restore-size files-by-contents blobs-per-file raw-data; do
+    size=$(./rapi stats --mode $mode --json | jq .total_size)
+    [ "$size" -eq "$(restic stats --mode $mode --json | jq .total_size)" ]
+  done
+}
+
+@test "rapi stats reports the encryption overhead in raw-data mode" {
+  run ./rapi stats --mode raw-data latest
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "Encryption Overhead:" ]]
+  blobs=$(./rapi stats --mode raw-data --json | jq .total_blob_count)
+  [ "$(./rapi stats --mode raw-data --json | jq .encryption_overhead)" -eq $((blobs * 32)) ]
+}
+
+@test "rapi stats filters snapshots" {
+  run ./rapi stats --json --host nonexistent
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | jq .snapshots_count)" -eq 0 ]
+}
+
+@test "rapi stats counts hard links once per snapshot" {
+  ./script/init-test-repo
+  dir=$(mktemp -d)
+  head -c 10000 /dev/urandom > "$dir/a"
+  ln "$dir/a" "$dir/b"
+  restic backup "$dir" > /dev/null
+  restic backup "$dir" > /dev/null
+  run ./rapi stats --json
+  [ "$status" -eq 0 ]
+  [ "$(echo "$output" | jq .total_size)" -eq 20000 ]
+  [ "$(echo "$output" | jq .total_file_count)" -eq 4 ]
+  rm -rf "$dir"
+}
@@ -0,0 +1,44 @@
+setup() {
+  ./script/init-test-repo
+  restic backup integration/fixtures > /dev/null
+}
+
+@test "rapi tag prints help" {
+  run ./rapi tag --help
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "--set-host" ]]
+}
+
+@test "rapi tag adds and removes tags" {
+  id=$(restic snapshots --json | jq -r '.[0].id')
+  run ./rapi tag --add foo,bar latest
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "modified 1 snapshots" ]]
+  [ "$(restic snapshots --json | jq -r '.[0].tags | join(",")')" = "foo,bar" ]
+  [ "$(restic snapshots --json | jq -r '.[0].original')" = "$id" ]
+  run ./rapi tag --remove foo latest
+  [ "$status" -eq 0 ]
+  [ "$(restic snapshots --json | jq -r '.[0].tags | join(",")')" = "bar" ]
+  [ "$(restic snapshots --json | jq length)" -eq 1 ]
+}
+
+@test "rapi tag sets the host and paths" {
+  run ./rapi tag --set-host other --set-path /foo latest
+  [ "$status" -eq 0 ]
+  [ "$(restic snapshots --json | jq -r '.[0].hostname')" = "other" ]
+  [ "$(restic snapshots --json | jq -r '.[0].paths[0]')" = "/foo" ]
+}
+
+@test "rapi tag does not modify unchanged snapshots" {
+  run ./rapi tag --remove foo latest
+  [ "$status" -eq 0 ]
+  [[ "$output" =~ "no snapshots were modified" ]]
+}
+
+@test "rapi tag requires a change" {
+  run ./rapi tag latest
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "nothing to do" ]]
+  run ./rapi tag --set foo --add bar latest
+  [ "$status" -eq 1 ]
+}
@@ -0,0 +1,131 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func init() {
+	register(&UpgradeRepoV2{})
+}
+
+// UpgradeRepoV2Error is returned when the config file could not be replaced.
+// BackupFilePath contains the location of a copy of the original config file.
+type UpgradeRepoV2Error struct {
+	UploadNewConfigError   error
+	ReuploadOldConfigError error
+
+	BackupFilePath string
+}
+
+func (err *UpgradeRepoV2Error) Error() string {
+	if err.ReuploadOldConfigError != nil {
+		return fmt.Sprintf("error uploading config (%v), re-uploading old config failed as well (%v), but there is a backup of the config file in %v", err.UploadNewConfigError, err.ReuploadOldConfigError, err.BackupFilePath)
+	}
+
+	return fmt.Sprintf("error uploading config (%v), re-uploaded old config was successful, there is a backup of the config file in %v", err.UploadNewConfigError, err.BackupFilePath)
+}
+
+func (err *UpgradeRepoV2Error) Unwrap() error {
+	// consider the original upload error as the primary cause
+	return err.UploadNewConfigError
+}
+
+// UpgradeRepoV2 upgrades a repository from version 1 to version 2, which
+// enables compression for all data written afterwards. Existing data is left
+// untouched, prune can be used to compress it.
+type UpgradeRepoV2 struct{}
+
+// Name returns a short name.
+func (*UpgradeRepoV2) Name() string {
+	return "upgrade_repo_v2"
+}
+
+// Desc returns a description what the migration does.
+func (*UpgradeRepoV2) Desc() string {
+	return "upgrade a repository to version 2"
+}
+
+// Check tests whether the migration can be applied.
+func (*UpgradeRepoV2) Check(ctx context.Context, repo restic.Repository) (bool, error) {
+	isV1 := repo.Config().Version == 1
+	return isV1, nil
+}
+
+func (*UpgradeRepoV2) upgrade(ctx context.Context, repo restic.Repository) error {
+	h := restic.Handle{Type: restic.ConfigFile}
+
+	// now remove the config file and save the new config
+	err := repo.Backend().Remove(ctx, h)
+	if err != nil {
+		return errors.Wrap(err, "remove config failed")
+	}
+
+	cfg := repo.Config()
+	cfg.Version = 2
+
+	_, err = repo.SaveJSONUnpacked(ctx, restic.ConfigFile, cfg)
+	if err != nil {
+		return errors.Wrap(err, "save new config file failed")
+	}
+
+	return nil
+}
+
+// Apply runs the migration. A copy of the original config file is kept in a
+// temporary directory until the new config file has been saved.
+func (m *UpgradeRepoV2) Apply(ctx context.Context, repo restic.Repository) error {
+	tempdir, err := ioutil.TempDir("", "rapi-migrate-upgrade-repo-v2-")
+	if err != nil {
+		return errors.Wrap(err, "create temp dir failed")
+	}
+
+	h := restic.Handle{Type: restic.ConfigFile}
+
+	// read raw config file and save it to a temp dir, just in case
+	var rawConfigFile []byte
+	err = repo.Backend().Load(ctx, h, 0, 0, func(rd io.Reader) (err error) {
+		rawConfigFile, err = ioutil.ReadAll(rd)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "load config file failed")
+	}
+
+	backupFileName := filepath.Join(tempdir, "config")
+	err = ioutil.WriteFile(backupFileName, rawConfigFile, 0600)
+	if err != nil {
+		return errors.Wrap(err, "write config file backup failed")
+	}
+
+	// run the upgrade
+	err = m.upgrade(ctx, repo)
+	if err != nil {
+
+		// build an error we can return to the caller
+		repoError := &UpgradeRepoV2Error{
+			UploadNewConfigError: err,
+			BackupFilePath:       backupFileName,
+		}
+
+		// try contingency methods, reupload the original file
+		_ = repo.Backend().Remove(ctx, h)
+		err = repo.Backend().Save(ctx, h, restic.NewByteReader(rawConfigFile, repo.Backend().Hasher()))
+		if err != nil {
+			repoError.ReuploadOldConfigError = err
+		}
+
+		return repoError
+	}
+
+	_ = os.Remove(backupFileName)
+	_ = os.Remove(tempdir)
+	return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+	rtest "github.com/rubiojr/rapi/internal/test"
+)
+
+func TestUpgradeRepoV2(t *testing.T) {
+	repo, cleanup := repository.TestRepositoryWithVersion(t, 1)
+	defer cleanup()
+
+	if repo.Config().Version != 1 {
+		t.Fatal("test repo has wrong version")
+	}
+
+	m := &UpgradeRepoV2{}
+
+	ok, err := m.Check(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "migration check returned false")
+
+	rtest.OK(t, m.Apply(context.Background(), repo))
+
+	cfg, err := restic.LoadConfig(context.Background(), repo)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint(2), cfg.Version)
+}
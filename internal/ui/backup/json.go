@@ -7,7 +7,7 @@ import (
 	"sort"
 	"time"
 
-	"github.com/rubiojr/rapi/internal/archiver"
+	"github.com/rubiojr/rapi/archiver"
 	"github.com/rubiojr/rapi/restic"
 	"github.com/rubiojr/rapi/internal/ui"
 	"github.com/rubiojr/rapi/internal/ui/termstatus"
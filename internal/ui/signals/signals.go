@@ -2,7 +2,9 @@ package signals
 
 import (
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 )
 
 // GetProgressChannel returns a channel with which a single listener
@@ -22,3 +24,19 @@ var signals struct {
 	ch chan os.Signal
 	sync.Once
 }
+
+// GetInterruptChannel returns a channel with which a single listener
+// receives SIGINT and SIGTERM, so it can clean up before exiting.
+func GetInterruptChannel() <-chan os.Signal {
+	interrupt.Once.Do(func() {
+		interrupt.ch = make(chan os.Signal, 1)
+		signal.Notify(interrupt.ch, os.Interrupt, syscall.SIGTERM)
+	})
+
+	return interrupt.ch
+}
+
+var interrupt struct {
+	ch chan os.Signal
+	sync.Once
+}
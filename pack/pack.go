@@ -32,6 +32,12 @@ func NewPacker(k *crypto.Key, wr io.Writer) *Packer {
 // Add saves the data read from rd as a new blob to the packer. Returned is the
 // number of bytes written to the pack.
 func (p *Packer) Add(t restic.BlobType, id restic.ID, data []byte) (int, error) {
+	return p.AddCompressed(t, id, data, 0)
+}
+
+// AddCompressed is like Add, but uncompressedLength is the length of the blob
+// content before it was compressed to data, or 0 if data is not compressed.
+func (p *Packer) AddCompressed(t restic.BlobType, id restic.ID, data []byte, uncompressedLength int) (int, error) {
 	p.m.Lock()
 	defer p.m.Unlock()
 
@@ -40,14 +46,20 @@ func (p *Packer) Add(t restic.BlobType, id restic.ID, data []byte) (int, error)
 	n, err := p.wr.Write(data)
 	c.Length = uint(n)
 	c.Offset = p.bytes
+	c.UncompressedLength = uint(uncompressedLength)
 	p.bytes += uint(n)
 	p.blobs = append(p.blobs, c)
 
 	return n, errors.Wrap(err, "Write")
 }
 
+// EntrySize is the size of a header entry of an uncompressed blob.
 var EntrySize = uint(binary.Size(restic.BlobType(0)) + headerLengthSize + len(restic.ID{}))
 
+// compressedEntrySize is the size of a header entry of a compressed blob,
+// which additionally stores the uncompressed length.
+var compressedEntrySize = uint(binary.Size(restic.BlobType(0)) + 2*headerLengthSize + len(restic.ID{}))
+
 // headerEntry describes the format of header entries. It serves only as
 // documentation.
 type headerEntry struct {
@@ -56,6 +68,15 @@ type headerEntry struct {
 	ID     restic.ID
 }
 
+// compressedHeaderEntry describes the format of header entries for
+// compressed blobs. It serves only as documentation.
+type compressedHeaderEntry struct {
+	Type               uint8
+	Length             uint32
+	UncompressedLength uint32
+	ID                 restic.ID
+}
+
 // Finalize writes the header for all added blobs and finalizes the pack.
 // Returned are the number of bytes written, including the header.
 func (p *Packer) Finalize() (uint, error) {
@@ -88,7 +109,7 @@ func (p *Packer) Finalize() (uint, error) {
 	bytesWritten += uint(hdrBytes)
 
 	// write length
-	err = binary.Write(p.wr, binary.LittleEndian, uint32(restic.CiphertextLength(len(p.blobs)*int(EntrySize))))
+	err = binary.Write(p.wr, binary.LittleEndian, uint32(hdrBytes))
 	if err != nil {
 		return 0, errors.Wrap(err, "binary.Write")
 	}
@@ -100,14 +121,18 @@ func (p *Packer) Finalize() (uint, error) {
 
 // makeHeader constructs the header for p.
 func (p *Packer) makeHeader() ([]byte, error) {
-	buf := make([]byte, 0, len(p.blobs)*int(EntrySize))
+	buf := make([]byte, 0, CalculateHeaderSize(p.blobs))
 
 	for _, b := range p.blobs {
-		switch b.Type {
-		case restic.DataBlob:
+		switch {
+		case b.Type == restic.DataBlob && b.UncompressedLength == 0:
 			buf = append(buf, 0)
-		case restic.TreeBlob:
+		case b.Type == restic.TreeBlob && b.UncompressedLength == 0:
 			buf = append(buf, 1)
+		case b.Type == restic.DataBlob && b.UncompressedLength != 0:
+			buf = append(buf, 2)
+		case b.Type == restic.TreeBlob && b.UncompressedLength != 0:
+			buf = append(buf, 3)
 		default:
 			return nil, errors.Errorf("invalid blob type %v", b.Type)
 		}
@@ -115,6 +140,10 @@ func (p *Packer) makeHeader() ([]byte, error) {
 		var lenLE [4]byte
 		binary.LittleEndian.PutUint32(lenLE[:], uint32(b.Length))
 		buf = append(buf, lenLE[:]...)
+		if b.UncompressedLength != 0 {
+			binary.LittleEndian.PutUint32(lenLE[:], uint32(b.UncompressedLength))
+			buf = append(buf, lenLE[:]...)
+		}
 		buf = append(buf, b.ID[:]...)
 	}
 
@@ -161,20 +190,15 @@ const (
 	HeaderSize = headerLengthSize + crypto.Extension
 
 	maxHeaderSize = 16 * 1024 * 1024
-	// number of header enries to download as part of header-length request
+	// number of header entries to download as part of header-length request
 	eagerEntries = 15
 )
 
-// readRecords reads up to max records from the underlying ReaderAt, returning
-// the raw header, the total number of records in the header, and any error.
-// If the header contains fewer than max entries, the header is truncated to
-// the appropriate size.
-func readRecords(rd io.ReaderAt, size int64, max int) ([]byte, int, error) {
-	var bufsize int
-	bufsize += max * int(EntrySize)
-	bufsize += crypto.Extension
-	bufsize += headerLengthSize
-
+// readRecords reads the last bufsize bytes of the pack, which is expected to
+// contain the header, returning the raw header, the total size of the header
+// including the header length field, and any error. If the header is smaller
+// than bufsize, it is truncated to the appropriate size.
+func readRecords(rd io.ReaderAt, size int64, bufsize int) ([]byte, int, error) {
 	if bufsize > int(size) {
 		bufsize = int(size)
 	}
@@ -195,8 +219,6 @@ func readRecords(rd io.ReaderAt, size int64, max int) ([]byte, int, error) {
 		err = InvalidFileError{Message: "header length is zero"}
 	case hlen < crypto.Extension:
 		err = InvalidFileError{Message: "header length is too small"}
-	case (hlen-crypto.Extension)%uint32(EntrySize) != 0:
-		err = InvalidFileError{Message: "header length is invalid"}
 	case int64(hlen) > size-int64(headerLengthSize):
 		err = InvalidFileError{Message: "header is larger than file"}
 	case int64(hlen) > maxHeaderSize:
@@ -206,8 +228,8 @@ func readRecords(rd io.ReaderAt, size int64, max int) ([]byte, int, error) {
 		return nil, 0, errors.Wrap(err, "readHeader")
 	}
 
-	total := (int(hlen) - crypto.Extension) / int(EntrySize)
-	if total < max {
+	total := int(hlen) + headerLengthSize
+	if total < bufsize {
 		// truncate to the beginning of the pack header
 		b = b[len(b)-int(hlen):]
 	}
@@ -228,15 +250,16 @@ func readHeader(rd io.ReaderAt, size int64) ([]byte, error) {
 	// eagerly download eagerEntries header entries as part of header-length request.
 	// only make second request if actual number of entries is greater than eagerEntries
 
-	b, c, err := readRecords(rd, size, eagerEntries)
+	eagerSize := eagerEntries*int(compressedEntrySize) + crypto.Extension + headerLengthSize
+	b, total, err := readRecords(rd, size, eagerSize)
 	if err != nil {
 		return nil, err
 	}
-	if c <= eagerEntries {
+	if total <= eagerSize {
 		// eager read sufficed, return what we got
 		return b, nil
 	}
-	b, _, err = readRecords(rd, size, c)
+	b, _, err = readRecords(rd, size, total)
 	if err != nil {
 		return nil, err
 	}
@@ -276,7 +299,7 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdr
 
 	pos := uint(0)
 	for len(buf) > 0 {
-		entry, err := parseHeaderEntry(buf)
+		entry, headerSize, err := parseHeaderEntry(buf)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -284,35 +307,68 @@ func List(k *crypto.Key, rd io.ReaderAt, size int64) (entries []restic.Blob, hdr
 
 		entries = append(entries, entry)
 		pos += entry.Length
-		buf = buf[EntrySize:]
+		buf = buf[headerSize:]
 	}
 
 	return entries, hdrSize, nil
 }
 
+// CalculateEntrySize returns the size of the header entry for the blob.
+func CalculateEntrySize(blob restic.Blob) int {
+	if blob.UncompressedLength != 0 {
+		return int(compressedEntrySize)
+	}
+	return int(EntrySize)
+}
+
+// CalculateHeaderSize returns the size of the unencrypted header for the
+// blobs.
+func CalculateHeaderSize(blobs []restic.Blob) int {
+	size := 0
+	for _, blob := range blobs {
+		size += CalculateEntrySize(blob)
+	}
+	return size
+}
+
 // PackedSizeOfBlob returns the size a blob actually uses when saved in a pack
+// as an uncompressed blob. Use CalculateEntrySize for compressed blobs.
 func PackedSizeOfBlob(blobLength uint) uint {
 	return blobLength + EntrySize
 }
 
-func parseHeaderEntry(p []byte) (b restic.Blob, err error) {
-	if uint(len(p)) < EntrySize {
+func parseHeaderEntry(p []byte) (b restic.Blob, size uint, err error) {
+	l := uint(len(p))
+	size = EntrySize
+	if l < EntrySize {
 		err = errors.Errorf("parseHeaderEntry: buffer of size %d too short", len(p))
-		return b, err
+		return b, size, err
 	}
-	p = p[:EntrySize]
 
-	switch p[0] {
-	case 0:
+	tpe := p[0]
+
+	switch tpe {
+	case 0, 2:
 		b.Type = restic.DataBlob
-	case 1:
+	case 1, 3:
 		b.Type = restic.TreeBlob
 	default:
-		return b, errors.Errorf("invalid type %d", p[0])
+		return b, size, errors.Errorf("invalid type %d", tpe)
 	}
 
 	b.Length = uint(binary.LittleEndian.Uint32(p[1:5]))
-	copy(b.ID[:], p[5:])
+	p = p[5:]
+	if tpe == 2 || tpe == 3 {
+		size = compressedEntrySize
+		if l < compressedEntrySize {
+			err = errors.Errorf("parseHeaderEntry: buffer of size %d too short", len(p))
+			return b, size, err
+		}
+		b.UncompressedLength = uint(binary.LittleEndian.Uint32(p[0:4]))
+		p = p[4:]
+	}
 
-	return b, nil
+	copy(b.ID[:], p[:len(b.ID)])
+
+	return b, size, nil
 }
@@ -23,25 +23,48 @@ func TestParseHeaderEntry(t *testing.T) {
 	buf := new(bytes.Buffer)
 	_ = binary.Write(buf, binary.LittleEndian, &h)
 
-	b, err := parseHeaderEntry(buf.Bytes())
+	b, size, err := parseHeaderEntry(buf.Bytes())
 	rtest.OK(t, err)
 	rtest.Equals(t, restic.DataBlob, b.Type)
+	rtest.Equals(t, EntrySize, size)
 	t.Logf("%v %v", h.ID, b.ID)
 	rtest.Assert(t, bytes.Equal(h.ID[:], b.ID[:]), "id mismatch")
 	rtest.Equals(t, uint(h.Length), b.Length)
+	rtest.Equals(t, uint(0), b.UncompressedLength)
+
+	c := compressedHeaderEntry{
+		Type:               3, // compressed tree
+		Length:             100,
+		UncompressedLength: 200,
+	}
+	copy(c.ID[:], h.ID[:])
+
+	buf.Reset()
+	_ = binary.Write(buf, binary.LittleEndian, &c)
+
+	b, size, err = parseHeaderEntry(buf.Bytes())
+	rtest.OK(t, err)
+	rtest.Equals(t, restic.TreeBlob, b.Type)
+	rtest.Equals(t, compressedEntrySize, size)
+	rtest.Assert(t, bytes.Equal(c.ID[:], b.ID[:]), "id mismatch")
+	rtest.Equals(t, uint(c.Length), b.Length)
+	rtest.Equals(t, uint(c.UncompressedLength), b.UncompressedLength)
+
+	_, _, err = parseHeaderEntry(buf.Bytes()[:compressedEntrySize-1])
+	rtest.Assert(t, err != nil, "no error for short input of compressed entry")
 
 	h.Type = 0xae
 	buf.Reset()
 	_ = binary.Write(buf, binary.LittleEndian, &h)
 
-	b, err = parseHeaderEntry(buf.Bytes())
+	_, _, err = parseHeaderEntry(buf.Bytes())
 	rtest.Assert(t, err != nil, "no error for invalid type")
 
 	h.Type = 0
 	buf.Reset()
 	_ = binary.Write(buf, binary.LittleEndian, &h)
 
-	b, err = parseHeaderEntry(buf.Bytes()[:EntrySize-1])
+	_, _, err = parseHeaderEntry(buf.Bytes()[:EntrySize-1])
 	rtest.Assert(t, err != nil, "no error for short input")
 }
 
@@ -58,7 +81,7 @@ func (rd *countingReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
 func TestReadHeaderEagerLoad(t *testing.T) {
 
 	testReadHeader := func(dataSize, entryCount, expectedReadInvocationCount int) {
-		expectedHeader := rtest.Random(0, entryCount*int(EntrySize)+crypto.Extension)
+		expectedHeader := rtest.Random(0, entryCount*int(compressedEntrySize)+crypto.Extension)
 
 		buf := &bytes.Buffer{}
 		buf.Write(rtest.Random(0, dataSize))                                             // pack blobs data
@@ -83,8 +106,8 @@ func TestReadHeaderEagerLoad(t *testing.T) {
 	testReadHeader(100, eagerEntries+1, 2)
 
 	// file size == eager header load size
-	eagerLoadSize := int((eagerEntries * EntrySize) + crypto.Extension)
-	headerSize := int(1*EntrySize) + crypto.Extension
+	eagerLoadSize := int((eagerEntries * compressedEntrySize) + crypto.Extension)
+	headerSize := int(1*compressedEntrySize) + crypto.Extension
 	dataSize := eagerLoadSize - headerSize - binary.Size(uint32(0))
 	testReadHeader(dataSize-1, 1, 1)
 	testReadHeader(dataSize, 1, 1)
@@ -96,8 +119,9 @@ func TestReadHeaderEagerLoad(t *testing.T) {
 
 func TestReadRecords(t *testing.T) {
 	testReadRecords := func(dataSize, entryCount, totalRecords int) {
-		totalHeader := rtest.Random(0, totalRecords*int(EntrySize)+crypto.Extension)
-		off := len(totalHeader) - (entryCount*int(EntrySize) + crypto.Extension)
+		totalHeader := rtest.Random(0, totalRecords*int(compressedEntrySize)+crypto.Extension)
+		bufSize := entryCount*int(compressedEntrySize) + crypto.Extension
+		off := len(totalHeader) - bufSize
 		if off < 0 {
 			off = 0
 		}
@@ -110,10 +134,10 @@ func TestReadRecords(t *testing.T) {
 
 		rd := bytes.NewReader(buf.Bytes())
 
-		header, count, err := readRecords(rd, int64(rd.Len()), entryCount)
+		header, count, err := readRecords(rd, int64(rd.Len()), bufSize+headerLengthSize)
 		rtest.OK(t, err)
 		rtest.Equals(t, expectedHeader, header)
-		rtest.Equals(t, totalRecords, count)
+		rtest.Equals(t, len(totalHeader)+headerLengthSize, count)
 	}
 
 	// basic
@@ -127,8 +151,8 @@ func TestReadRecords(t *testing.T) {
 	testReadRecords(100, eagerEntries, eagerEntries+1)
 
 	// file size == eager header load size
-	eagerLoadSize := int((eagerEntries * EntrySize) + crypto.Extension)
-	headerSize := int(1*EntrySize) + crypto.Extension
+	eagerLoadSize := int((eagerEntries * compressedEntrySize) + crypto.Extension)
+	headerSize := int(1*compressedEntrySize) + crypto.Extension
 	dataSize := eagerLoadSize - headerSize - binary.Size(uint32(0))
 	testReadRecords(dataSize-1, 1, 1)
 	testReadRecords(dataSize, 1, 1)
@@ -22,24 +22,32 @@ var testLens = []int{23, 31650, 25860, 10928, 13769, 19862, 5211, 127, 13690, 30
 type Buf struct {
 	data []byte
 	id   restic.ID
+
+	uncompressedLength int
 }
 
 func newPack(t testing.TB, k *crypto.Key, lengths []int) ([]Buf, []byte, uint) {
 	bufs := []Buf{}
 
-	for _, l := range lengths {
+	for i, l := range lengths {
 		b := make([]byte, l)
 		_, err := io.ReadFull(rand.Reader, b)
 		rtest.OK(t, err)
 		h := sha256.Sum256(b)
-		bufs = append(bufs, Buf{data: b, id: h})
+		// mark every second blob as compressed, the packer does not look
+		// at the data
+		uncompressedLength := 0
+		if i%2 == 1 {
+			uncompressedLength = 2 * l
+		}
+		bufs = append(bufs, Buf{data: b, id: h, uncompressedLength: uncompressedLength})
 	}
 
 	// pack blobs
 	var buf bytes.Buffer
 	p := pack.NewPacker(k, &buf)
 	for _, b := range bufs {
-		_, err := p.Add(restic.TreeBlob, b.id, b.data)
+		_, err := p.AddCompressed(restic.TreeBlob, b.id, b.data, b.uncompressedLength)
 		rtest.OK(t, err)
 	}
 
@@ -51,11 +59,13 @@ func newPack(t testing.TB, k *crypto.Key, lengths []int) ([]Buf, []byte, uint) {
 
 func verifyBlobs(t testing.TB, bufs []Buf, k *crypto.Key, rd io.ReaderAt, packSize uint) {
 	written := 0
+	var blobs []restic.Blob
 	for _, buf := range bufs {
 		written += len(buf.data)
+		blobs = append(blobs, restic.Blob{UncompressedLength: uint(buf.uncompressedLength)})
 	}
 	// header length + header + header crypto
-	headerSize := binary.Size(uint32(0)) + restic.CiphertextLength(len(bufs)*int(pack.EntrySize))
+	headerSize := binary.Size(uint32(0)) + restic.CiphertextLength(pack.CalculateHeaderSize(blobs))
 	written += headerSize
 
 	// check length
@@ -71,6 +81,7 @@ func verifyBlobs(t testing.TB, bufs []Buf, k *crypto.Key, rd io.ReaderAt, packSi
 	for i, b := range bufs {
 		e := entries[i]
 		rtest.Equals(t, b.id, e.ID)
+		rtest.Equals(t, uint(b.uncompressedLength), e.UncompressedLength)
 
 		if len(buf) < int(e.Length) {
 			buf = make([]byte, int(e.Length))
@@ -35,6 +35,8 @@ import (
 
 	"os/exec"
 
+	"github.com/restic/chunker"
+
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -221,6 +223,15 @@ func resolvePassword(opts ResticOptions, envStr string) (string, error) {
 	return "", nil
 }
 
+// repositoryPassword returns the password given in opts or, if it was not
+// set explicitly, the one from the password file, command or environment.
+func repositoryPassword(opts ResticOptions) (string, error) {
+	if opts.Password != "" {
+		return opts.Password, nil
+	}
+	return resolvePassword(opts, "RESTIC_PASSWORD")
+}
+
 // readPassword reads the password from the given reader directly.
 func readPassword(in io.Reader) (password string, err error) {
 	sc := bufio.NewScanner(in)
@@ -302,7 +313,8 @@ func ReadRepo(opts ResticOptions) (string, error) {
 		return "", errors.Fatal("Please specify repository location (-r or --repository-file)")
 	}
 
-	if envRepo != "" {
+	// the environment is only used when no repository was given explicitly
+	if opts.Repo == "" && opts.RepositoryFile == "" {
 		return envRepo, nil
 	}
 
@@ -328,14 +340,20 @@ func ReadRepo(opts ResticOptions) (string, error) {
 
 const maxKeys = 20
 
-// OpenRepository reads the password and opens the repository.
-func OpenRepository(opts ResticOptions) (*repository.Repository, error) {
+// OpenBackend opens the backend of the repository given in opts, without
+// reading the password or any of its keys.
+func OpenBackend(opts ResticOptions) (restic.Backend, error) {
 	repo, err := ReadRepo(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	be, err := open(repo, opts, opts.extended)
+	return open(repo, opts, opts.extended)
+}
+
+// OpenRepository reads the password and opens the repository.
+func OpenRepository(opts ResticOptions) (*repository.Repository, error) {
+	be, err := OpenBackend(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -352,15 +370,11 @@ func OpenRepository(opts ResticOptions) (*repository.Repository, error) {
 		}
 	}
 
-	pwd, err := resolvePassword(opts, "RESTIC_PASSWORD")
+	opts.Password, err = repositoryPassword(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if pwd != "" {
-		opts.Password = pwd
-	}
-
 	s := repository.New(be)
 
 	passwordTriesLeft := 1
@@ -449,6 +463,44 @@ func OpenRepository(opts ResticOptions) (*repository.Repository, error) {
 	return s, nil
 }
 
+// InitRepository creates the backend and initializes a new repository in it
+// with the given version and chunker polynomial. A random polynomial is used
+// when chunkerPolynomial is nil, version 0 selects the stable version.
+//
+// The password is read like OpenRepository does, the user is asked for it
+// twice when prompting.
+func InitRepository(opts ResticOptions, version uint, chunkerPolynomial *chunker.Pol) (*repository.Repository, error) {
+	repo, err := ReadRepo(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Password, err = repositoryPassword(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Password, err = ReadPasswordTwice(opts,
+		"enter password for new repository: ",
+		"enter password again: ")
+	if err != nil {
+		return nil, err
+	}
+
+	be, err := create(repo, opts, opts.extended)
+	if err != nil {
+		return nil, errors.Fatalf("create repository at %s failed: %v\n", location.StripPassword(repo), err)
+	}
+
+	s := repository.New(be)
+	err = s.InitWithVersion(opts.ctx, version, opts.Password, chunkerPolynomial)
+	if err != nil {
+		return nil, errors.Fatalf("create key in repository at %s failed: %v\n", location.StripPassword(repo), err)
+	}
+
+	return s, nil
+}
+
 func parseConfig(loc location.Location, opts options.Options) (interface{}, error) {
 	// only apply options for a particular backend here
 	opts = opts.Extract(loc.Scheme)
@@ -655,3 +707,51 @@ func open(s string, gopts ResticOptions, opts options.Options) (restic.Backend,
 
 	return be, nil
 }
+
+// Create the backend specified by a location config.
+func create(s string, gopts ResticOptions, opts options.Options) (restic.Backend, error) {
+	debug.Log("parsing location %v", location.StripPassword(s))
+	loc, err := location.Parse(s)
+	if err != nil {
+		return nil, errors.Fatalf("parsing repository location failed: %v", err)
+	}
+
+	cfg, err := parseConfig(loc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tropts := backend.TransportOptions{
+		RootCertFilenames:        DefaultOptions.CACerts,
+		TLSClientCertKeyFilename: DefaultOptions.TLSClientCert,
+	}
+	rt, err := backend.Transport(tropts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch loc.Scheme {
+	case "local":
+		return local.Create(gopts.ctx, cfg.(local.Config))
+	case "sftp":
+		return sftp.Create(gopts.ctx, cfg.(sftp.Config))
+	case "s3":
+		return s3.Create(gopts.ctx, cfg.(s3.Config), rt)
+	case "gs":
+		return gs.Create(cfg.(gs.Config), rt)
+	case "azure":
+		return azure.Create(cfg.(azure.Config), rt)
+	case "swift":
+		// Open creates the container if it does not exist
+		return swift.Open(gopts.ctx, cfg.(swift.Config), rt)
+	case "b2":
+		return b2.Create(gopts.ctx, cfg.(b2.Config), rt)
+	case "rest":
+		return rest.Create(gopts.ctx, cfg.(rest.Config), rt)
+	case "rclone":
+		return rclone.Create(gopts.ctx, cfg.(rclone.Config))
+	}
+
+	debug.Log("invalid repository scheme: %v", s)
+	return nil, errors.Fatalf("invalid scheme %q", loc.Scheme)
+}
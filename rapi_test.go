@@ -0,0 +1,66 @@
+package rapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/rubiojr/rapi/internal/test"
+)
+
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	rtest.OK(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	})
+}
+
+func TestReadRepoPrecedence(t *testing.T) {
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	repoFile := filepath.Join(tempdir, "repo")
+	rtest.OK(t, ioutil.WriteFile(repoFile, []byte("/from/file\n"), 0600))
+
+	setenv(t, "RESTIC_REPOSITORY", "/from/env")
+
+	repo, err := ReadRepo(ResticOptions{})
+	rtest.OK(t, err)
+	rtest.Equals(t, "/from/env", repo)
+
+	repo, err = ReadRepo(ResticOptions{Repo: "/from/flag"})
+	rtest.OK(t, err)
+	rtest.Equals(t, "/from/flag", repo)
+
+	repo, err = ReadRepo(ResticOptions{RepositoryFile: repoFile})
+	rtest.OK(t, err)
+	rtest.Equals(t, "/from/file", repo)
+}
+
+func TestRepositoryPasswordPrecedence(t *testing.T) {
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	passwordFile := filepath.Join(tempdir, "password")
+	rtest.OK(t, ioutil.WriteFile(passwordFile, []byte("from file\n"), 0600))
+
+	setenv(t, "RESTIC_PASSWORD", "from env")
+
+	pw, err := repositoryPassword(ResticOptions{})
+	rtest.OK(t, err)
+	rtest.Equals(t, "from env", pw)
+
+	pw, err = repositoryPassword(ResticOptions{PasswordFile: passwordFile})
+	rtest.OK(t, err)
+	rtest.Equals(t, "from file", pw)
+
+	pw, err = repositoryPassword(ResticOptions{Password: "from flag", PasswordFile: passwordFile})
+	rtest.OK(t, err)
+	rtest.Equals(t, "from flag", pw)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// CopySnapshot copies the snapshot sn and all the tree and data blobs it
+// references from src to dst, and returns the ID of the new snapshot. Blobs
+// already present in the index of dst are not copied again. The repositories
+// may use different keys and chunker polynomials, as blobs are decrypted
+// when loaded from src and encrypted again when saved to dst.
+//
+// The new snapshot has Original set to the ID of sn, or to sn.Original if
+// sn is a copy itself, so copies can be identified with FindCopy.
+//
+// The indexes of both repositories must have been loaded.
+func CopySnapshot(ctx context.Context, src, dst restic.Repository, sn *restic.Snapshot) (restic.ID, error) {
+	blobs := restic.NewBlobSet()
+	err := restic.FindUsedBlobs(ctx, src, restic.IDs{*sn.Tree}, blobs, nil)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	var buf []byte
+	for h := range blobs {
+		if dst.Index().Has(h) {
+			continue
+		}
+
+		buf, err = src.LoadBlob(ctx, h.Type, h.ID, buf)
+		if err != nil {
+			return restic.ID{}, err
+		}
+
+		_, _, err = dst.SaveBlob(ctx, h.Type, buf, h.ID, false)
+		if err != nil {
+			return restic.ID{}, err
+		}
+	}
+	debug.Log("copied blobs of snapshot %v", sn.ID())
+
+	// make sure all the blobs are saved before the snapshot references them
+	if err = dst.Flush(ctx); err != nil {
+		return restic.ID{}, err
+	}
+
+	newSn := *sn
+	newSn.Original = sn.ID()
+	if sn.Original != nil && !sn.Original.IsNull() {
+		newSn.Original = sn.Original
+	}
+	// the parent is not in the destination repository
+	newSn.Parent = nil
+
+	return dst.SaveJSONUnpacked(ctx, restic.SnapshotFile, &newSn)
+}
+
+// FindCopy returns the snapshot from snapshots which is a copy of sn, or sn
+// itself, and nil if there is none. A snapshot is considered a copy when it
+// shares the same original snapshot and all of its fields except Parent and
+// Original are equal.
+func FindCopy(snapshots restic.Snapshots, sn *restic.Snapshot) *restic.Snapshot {
+	for _, s := range snapshots {
+		if originalID(s).Equal(originalID(sn)) && similarSnapshots(s, sn) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func originalID(sn *restic.Snapshot) restic.ID {
+	if sn.Original != nil && !sn.Original.IsNull() {
+		return *sn.Original
+	}
+	return *sn.ID()
+}
+
+func similarSnapshots(sna, snb *restic.Snapshot) bool {
+	if !sna.Time.Equal(snb.Time) || !sna.Tree.Equal(*snb.Tree) || sna.Hostname != snb.Hostname ||
+		sna.Username != snb.Username || sna.UID != snb.UID || sna.GID != snb.GID ||
+		len(sna.Paths) != len(snb.Paths) || len(sna.Excludes) != len(snb.Excludes) ||
+		len(sna.Tags) != len(snb.Tags) {
+		return false
+	}
+
+	if !sna.HasPaths(snb.Paths) || !sna.HasTags(snb.Tags) {
+		return false
+	}
+
+	for i, a := range sna.Excludes {
+		if a != snb.Excludes[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rubiojr/rapi/checker"
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestCopySnapshot(t *testing.T) {
+	ctx := context.TODO()
+
+	src, srcCleanup := repository.TestRepositoryWithVersion(t, 1)
+	defer srcCleanup()
+	dst, dstCleanup := repository.TestRepositoryWithVersion(t, 2)
+	defer dstCleanup()
+
+	sn1 := restic.TestCreateSnapshot(t, src, time.Unix(1500000000, 0), 3, 0)
+	sn2 := restic.TestCreateSnapshot(t, src, time.Unix(1500000600, 0), 3, 0)
+
+	id1, err := repository.CopySnapshot(ctx, src, dst, sn1)
+	rtest.OK(t, err)
+
+	copied, err := restic.LoadSnapshot(ctx, dst, id1)
+	rtest.OK(t, err)
+	rtest.Equals(t, *sn1.ID(), *copied.Original)
+	rtest.Equals(t, *sn1.Tree, *copied.Tree)
+
+	// copying a copy keeps the ID of the original snapshot
+	id2, err := repository.CopySnapshot(ctx, src, dst, sn2)
+	rtest.OK(t, err)
+	copied2, err := restic.LoadSnapshot(ctx, dst, id2)
+	rtest.OK(t, err)
+
+	back, backCleanup := repository.TestRepository(t)
+	defer backCleanup()
+	id3, err := repository.CopySnapshot(ctx, dst, back, copied2)
+	rtest.OK(t, err)
+	copied3, err := restic.LoadSnapshot(ctx, back, id3)
+	rtest.OK(t, err)
+	rtest.Equals(t, *sn2.ID(), *copied3.Original)
+
+	checker.TestCheckRepo(t, dst)
+	checker.TestCheckRepo(t, back)
+
+	srcBlobs := restic.NewBlobSet()
+	rtest.OK(t, restic.FindUsedBlobs(ctx, src, restic.IDs{*sn1.Tree, *sn2.Tree}, srcBlobs, nil))
+	for h := range srcBlobs {
+		rtest.Assert(t, dst.Index().Has(h), "blob %v was not copied", h)
+	}
+}
+
+func TestFindCopy(t *testing.T) {
+	ctx := context.TODO()
+
+	src, srcCleanup := repository.TestRepository(t)
+	defer srcCleanup()
+	dst, dstCleanup := repository.TestRepository(t)
+	defer dstCleanup()
+
+	sn1 := restic.TestCreateSnapshot(t, src, time.Unix(1500000000, 0), 1, 0)
+	sn2 := restic.TestCreateSnapshot(t, src, time.Unix(1500000600, 0), 1, 0)
+
+	_, err := repository.CopySnapshot(ctx, src, dst, sn1)
+	rtest.OK(t, err)
+
+	dstSnapshots, err := restic.FindFilteredSnapshots(ctx, dst, nil, nil, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(dstSnapshots))
+
+	found := repository.FindCopy(dstSnapshots, sn1)
+	rtest.Assert(t, found != nil, "copy of snapshot %v not found", sn1.ID().Str())
+	rtest.Equals(t, *sn1.ID(), *found.Original)
+
+	rtest.Assert(t, repository.FindCopy(dstSnapshots, sn2) == nil,
+		"snapshot %v found although it was not copied", sn2.ID().Str())
+
+	// the original is found in a list with the copy
+	srcSnapshots, err := restic.FindFilteredSnapshots(ctx, src, nil, nil, nil)
+	rtest.OK(t, err)
+	found = repository.FindCopy(srcSnapshots, dstSnapshots[0])
+	rtest.Assert(t, found != nil && found.ID().Equal(*sn1.ID()),
+		"original snapshot of %v not found", dstSnapshots[0].ID().Str())
+}
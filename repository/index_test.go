@@ -356,6 +356,45 @@ func TestIndexUnserializeOld(t *testing.T) {
 	rtest.Equals(t, 0, len(idx.Supersedes()))
 }
 
+func TestIndexUncompressedLength(t *testing.T) {
+	idx := repository.NewIndex()
+
+	packID := restic.NewRandomID()
+	plain := restic.Blob{
+		BlobHandle: restic.NewRandomBlobHandle(),
+		Offset:     0,
+		Length:     100,
+	}
+	compressed := restic.Blob{
+		BlobHandle:         restic.NewRandomBlobHandle(),
+		Offset:             100,
+		Length:             50,
+		UncompressedLength: 1000,
+	}
+	idx.StorePack(packID, []restic.Blob{plain, compressed})
+	idx.Finalize()
+
+	var buf bytes.Buffer
+	rtest.OK(t, idx.Encode(&buf))
+	rtest.Assert(t, bytes.Contains(buf.Bytes(), []byte(`"uncompressed_length":1000`)),
+		"uncompressed_length missing from encoded index: %s", buf.Bytes())
+	rtest.Assert(t, bytes.Count(buf.Bytes(), []byte("uncompressed_length")) == 1,
+		"uncompressed_length written for uncompressed blob: %s", buf.Bytes())
+
+	idx2, _, err := repository.DecodeIndex(buf.Bytes(), restic.NewRandomID())
+	rtest.OK(t, err)
+
+	for _, blob := range []restic.Blob{plain, compressed} {
+		list := idx2.Lookup(blob.BlobHandle, nil)
+		rtest.Assert(t, len(list) == 1, "expected one result for blob %v, got %v", blob.ID.Str(), len(list))
+		rtest.Equals(t, blob, list[0].Blob)
+
+		size, found := idx2.LookupSize(blob.BlobHandle)
+		rtest.Assert(t, found, "size of blob %v not found", blob.ID.Str())
+		rtest.Equals(t, blob.DataLength(), size)
+	}
+}
+
 func TestIndexPacks(t *testing.T) {
 	idx := repository.NewIndex()
 	packs := restic.NewIDSet()
@@ -80,3 +80,9 @@ func loadKey(reader io.Reader) (k *Key, err error) {
 
 	return k, nil
 }
+
+// Master returns the master key decrypted by OpenKey or Open, used to
+// encrypt the repository data.
+func (k *Key) Master() *crypto.Key {
+	return k.master
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// lockRefreshInterval is how often the locks are refreshed, well below the
+// 30 minutes after which other processes consider a lock stale.
+var lockRefreshInterval = 5 * time.Minute
+
+// TestSetLockRefreshInterval can be used to refresh the locks more often in
+// tests.
+func TestSetLockRefreshInterval(t testing.TB, d time.Duration) {
+	t.Logf("setting lock refresh interval to %v", d)
+	lockRefreshInterval = d
+}
+
+// Lock is a lock on a repository which is refreshed in the background until
+// it is released.
+type Lock struct {
+	lock *restic.Lock
+
+	stop chan struct{}
+	lost chan struct{}
+	done chan struct{}
+
+	once sync.Once
+	err  error
+}
+
+// NewLock locks repo, exclusively if exclusive is set, and keeps refreshing
+// the lock until Unlock is called. An error is returned if the repository is
+// already locked by another process, see restic.IsAlreadyLocked.
+func NewLock(ctx context.Context, repo restic.Repository, exclusive bool) (*Lock, error) {
+	var lock *restic.Lock
+	var err error
+	if exclusive {
+		lock, err = restic.NewExclusiveLock(ctx, repo)
+	} else {
+		lock, err = restic.NewLock(ctx, repo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	debug.Log("repository locked, exclusive %v", exclusive)
+
+	l := &Lock{
+		lock: lock,
+		stop: make(chan struct{}),
+		lost: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go l.refresh(ctx)
+
+	return l, nil
+}
+
+func (l *Lock) refresh(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.lock.Refresh(ctx); err != nil {
+				debug.Log("unable to refresh lock: %v", err)
+				// other processes will consider the lock stale soon,
+				// the repository is not protected any more
+				l.err = errors.Wrap(err, "refreshing the repository lock")
+				close(l.lost)
+				return
+			}
+		}
+	}
+}
+
+// Lost returns a channel which is closed when the lock could not be
+// refreshed. The lock should be released as soon as possible then.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock stops refreshing the lock and removes it from the repository. If
+// the lock was lost, the error refreshing it is returned. Unlock may be
+// called several times.
+func (l *Lock) Unlock() error {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+
+		err := l.lock.Unlock()
+		if l.err == nil && err != nil {
+			l.err = errors.Wrap(err, "removing the repository lock")
+		}
+		debug.Log("repository unlocked")
+	})
+
+	return l.err
+}
+
+// WithLock locks the repository, exclusively if exclusive is set, runs fn
+// and releases the lock. The lock is refreshed while fn runs, the context
+// passed to fn is cancelled if that fails.
+func (r *Repository) WithLock(ctx context.Context, exclusive bool, fn func(ctx context.Context) error) error {
+	lock, err := NewLock(ctx, r, exclusive)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-lock.Lost():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = fn(ctx)
+	if uerr := lock.Unlock(); err == nil {
+		err = uerr
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func listLocks(t testing.TB, repo restic.Repository) restic.IDs {
+	var ids restic.IDs
+	err := repo.List(context.TODO(), restic.LockFile, func(id restic.ID, size int64) error {
+		ids = append(ids, id)
+		return nil
+	})
+	rtest.OK(t, err)
+	return ids
+}
+
+func TestWithLock(t *testing.T) {
+	restic.TestSetLockTimeout(t, 5*time.Millisecond)
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	called := false
+	err := repo.(*repository.Repository).WithLock(context.TODO(), true, func(ctx context.Context) error {
+		called = true
+		rtest.Equals(t, 1, len(listLocks(t, repo)))
+
+		// another process cannot take a lock now
+		_, err := restic.NewLock(ctx, repo)
+		rtest.Assert(t, restic.IsAlreadyLocked(err), "expected ErrAlreadyLocked, got %v", err)
+		return nil
+	})
+	rtest.OK(t, err)
+	rtest.Assert(t, called, "function was not called")
+	rtest.Equals(t, 0, len(listLocks(t, repo)))
+}
+
+func TestWithLockAlreadyLocked(t *testing.T) {
+	restic.TestSetLockTimeout(t, 5*time.Millisecond)
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	lock, err := restic.NewLock(context.TODO(), repo)
+	rtest.OK(t, err)
+	defer func() {
+		rtest.OK(t, lock.Unlock())
+	}()
+
+	err = repo.(*repository.Repository).WithLock(context.TODO(), true, func(ctx context.Context) error {
+		t.Fatal("function called without holding the lock")
+		return nil
+	})
+	rtest.Assert(t, restic.IsAlreadyLocked(err), "expected ErrAlreadyLocked, got %v", err)
+
+	// shared locks do not conflict
+	err = repo.(*repository.Repository).WithLock(context.TODO(), false, func(ctx context.Context) error {
+		return nil
+	})
+	rtest.OK(t, err)
+}
+
+func TestLockRefresh(t *testing.T) {
+	restic.TestSetLockTimeout(t, 5*time.Millisecond)
+	repository.TestSetLockRefreshInterval(t, 20*time.Millisecond)
+	defer repository.TestSetLockRefreshInterval(t, 5*time.Minute)
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	lock, err := repository.NewLock(context.TODO(), repo, false)
+	rtest.OK(t, err)
+
+	ids := listLocks(t, repo)
+	rtest.Equals(t, 1, len(ids))
+
+	// refreshing replaces the lock file with a new one
+	time.Sleep(100 * time.Millisecond)
+	refreshed := listLocks(t, repo)
+	rtest.Equals(t, 1, len(refreshed))
+	rtest.Assert(t, !ids[0].Equal(refreshed[0]), "lock %v was not refreshed", ids[0].Str())
+
+	rtest.OK(t, lock.Unlock())
+	rtest.OK(t, lock.Unlock())
+	rtest.Equals(t, 0, len(listLocks(t, repo)))
+}
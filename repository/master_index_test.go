@@ -7,7 +7,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/rubiojr/rapi/internal/checker"
+	"github.com/rubiojr/rapi/checker"
 	"github.com/rubiojr/rapi/repository"
 	"github.com/rubiojr/rapi/restic"
 	rtest "github.com/rubiojr/rapi/internal/test"
@@ -5,6 +5,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/rubiojr/rapi/crypto"
 	"github.com/rubiojr/rapi/internal/debug"
 	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/internal/fs"
@@ -133,6 +134,13 @@ func Repack(ctx context.Context, repo restic.Repository, packs restic.IDSet, kee
 					return err
 				}
 
+				if entry.IsCompressed() {
+					plaintext, err = crypto.Decompress(make([]byte, 0, entry.DataLength()), plaintext)
+					if err != nil {
+						return err
+					}
+				}
+
 				id := restic.Hash(plaintext)
 				if !id.Equal(entry.ID) {
 					debug.Log("read blob %v/%v from %v: wrong data returned, hash is %v",
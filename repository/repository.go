@@ -124,9 +124,51 @@ func (r *Repository) LoadAndDecrypt(ctx context.Context, buf []byte, t restic.Fi
 		return nil, err
 	}
 
+	if t != restic.ConfigFile {
+		return r.decompressUnpacked(plaintext)
+	}
+
 	return plaintext, nil
 }
 
+// decompressUnpacked returns the content of an unpacked file. Starting with
+// repository version 2, unpacked files are either plain JSON or a version byte
+// followed by the zstd compressed JSON.
+func (r *Repository) decompressUnpacked(p []byte) ([]byte, error) {
+	// compression is only available starting from version 2
+	if r.cfg.Version < 2 {
+		return p, nil
+	}
+
+	if len(p) == 0 {
+		// too short for version header
+		return p, nil
+	}
+	if p[0] == '[' || p[0] == '{' {
+		// probably raw JSON
+		return p, nil
+	}
+	// version
+	if p[0] != 2 {
+		return nil, errors.New("not supported encoding format")
+	}
+
+	return crypto.Decompress(nil, p[1:])
+}
+
+// compressUnpacked compresses the content of an unpacked file for
+// repositories with version 2 and prepends the version byte.
+func (r *Repository) compressUnpacked(p []byte) []byte {
+	// compression is only available starting from version 2
+	if r.cfg.Version < 2 {
+		return p
+	}
+
+	// version byte
+	out := []byte{2}
+	return crypto.Compress(out, p)
+}
+
 type haver interface {
 	Has(restic.Handle) bool
 }
@@ -216,15 +258,27 @@ func (r *Repository) LoadBlob(ctx context.Context, t restic.BlobType, id restic.
 			continue
 		}
 
+		if blob.IsCompressed() {
+			plaintext, err = crypto.Decompress(make([]byte, 0, blob.DataLength()), plaintext)
+			if err != nil {
+				lastError = errors.Errorf("decompressing blob %v failed: %v", id, err)
+				continue
+			}
+		}
+
 		// check hash
 		if !restic.Hash(plaintext).Equal(id) {
 			lastError = errors.Errorf("blob %v returned invalid hash", id)
 			continue
 		}
 
+		if len(plaintext) > cap(buf) {
+			return plaintext, nil
+		}
 		// move decrypted data to the start of the buffer
+		buf = buf[:len(plaintext)]
 		copy(buf, plaintext)
-		return buf[:len(plaintext)], nil
+		return buf, nil
 	}
 
 	if lastError != nil {
@@ -256,6 +310,13 @@ func (r *Repository) LookupBlobSize(id restic.ID, tpe restic.BlobType) (uint, bo
 func (r *Repository) SaveAndEncrypt(ctx context.Context, t restic.BlobType, data []byte, id restic.ID) error {
 	debug.Log("save id %v (%v, %d bytes)", id, t, len(data))
 
+	uncompressedLength := 0
+	if r.cfg.Version > 1 {
+		// compression is available starting from version 2
+		uncompressedLength = len(data)
+		data = crypto.Compress(nil, data)
+	}
+
 	nonce := crypto.NewRandomNonce()
 
 	ciphertext := make([]byte, 0, restic.CiphertextLength(len(data)))
@@ -282,7 +343,7 @@ func (r *Repository) SaveAndEncrypt(ctx context.Context, t restic.BlobType, data
 	}
 
 	// save ciphertext
-	_, err = packer.Add(t, id, ciphertext)
+	_, err = packer.AddCompressed(t, id, ciphertext, uncompressedLength)
 	if err != nil {
 		return err
 	}
@@ -313,6 +374,10 @@ func (r *Repository) SaveJSONUnpacked(ctx context.Context, t restic.FileType, it
 // SaveUnpacked encrypts data and stores it in the backend. Returned is the
 // storage hash.
 func (r *Repository) SaveUnpacked(ctx context.Context, t restic.FileType, p []byte) (id restic.ID, err error) {
+	if t != restic.ConfigFile {
+		p = r.compressUnpacked(p)
+	}
+
 	ciphertext := restic.NewBlobBuffer(len(p))
 	ciphertext = ciphertext[:0]
 	nonce := crypto.NewRandomNonce()
@@ -598,8 +663,19 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 }
 
 // Init creates a new master key with the supplied password, initializes and
-// saves the repository config.
+// saves the repository config for a repository with
+// restic.StableRepoVersion.
 func (r *Repository) Init(ctx context.Context, password string, chunkerPolynomial *chunker.Pol) error {
+	return r.InitWithVersion(ctx, restic.StableRepoVersion, password, chunkerPolynomial)
+}
+
+// InitWithVersion is like Init, but creates a repository with the given
+// version. When version is 0, restic.StableRepoVersion is used.
+func (r *Repository) InitWithVersion(ctx context.Context, version uint, password string, chunkerPolynomial *chunker.Pol) error {
+	if version == 0 {
+		version = restic.StableRepoVersion
+	}
+
 	has, err := r.be.Test(ctx, restic.Handle{Type: restic.ConfigFile})
 	if err != nil {
 		return err
@@ -608,7 +684,7 @@ func (r *Repository) Init(ctx context.Context, password string, chunkerPolynomia
 		return errors.New("repository master key and config already initialized")
 	}
 
-	cfg, err := restic.CreateConfig()
+	cfg, err := restic.CreateConfigWithVersion(version)
 	if err != nil {
 		return err
 	}
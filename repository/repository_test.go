@@ -6,13 +6,14 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
-	"github.com/rubiojr/rapi/internal/archiver"
+	"github.com/rubiojr/rapi/archiver"
 	"github.com/rubiojr/rapi/internal/errors"
 	"github.com/rubiojr/rapi/internal/fs"
 	"github.com/rubiojr/rapi/repository"
@@ -25,7 +26,11 @@ var testSizes = []int{5, 23, 2<<18 + 23, 1 << 20}
 var rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
 
 func TestSave(t *testing.T) {
-	repo, cleanup := repository.TestRepository(t)
+	repository.TestAllVersions(t, testSave)
+}
+
+func testSave(t *testing.T, version uint) {
+	repo, cleanup := repository.TestRepositoryWithVersion(t, version)
 	defer cleanup()
 
 	for _, size := range testSizes {
@@ -59,6 +64,77 @@ func TestSave(t *testing.T) {
 	}
 }
 
+func TestSaveCompressed(t *testing.T) {
+	repository.TestAllVersions(t, testSaveCompressed)
+}
+
+func testSaveCompressed(t *testing.T, version uint) {
+	repo, cleanup := repository.TestRepositoryWithVersion(t, version)
+	defer cleanup()
+
+	// highly compressible data
+	data := bytes.Repeat([]byte("rapi compression test "), 1<<14)
+
+	id, _, err := repo.SaveBlob(context.TODO(), restic.DataBlob, data, restic.ID{}, false)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.Flush(context.Background()))
+
+	pbs := repo.Index().Lookup(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+	rtest.Assert(t, len(pbs) == 1, "expected one index entry, got %v", len(pbs))
+
+	blob := pbs[0].Blob
+	rtest.Equals(t, version > 1, blob.IsCompressed())
+	rtest.Equals(t, uint(len(data)), blob.DataLength())
+	if version > 1 {
+		rtest.Assert(t, blob.Length < uint(len(data)),
+			"compressed blob is not smaller than the data: %d >= %d", blob.Length, len(data))
+	}
+
+	size, found := repo.LookupBlobSize(id, restic.DataBlob)
+	rtest.Assert(t, found, "blob size not found")
+	rtest.Equals(t, uint(len(data)), size)
+
+	buf, err := repo.LoadBlob(context.TODO(), restic.DataBlob, id, nil)
+	rtest.OK(t, err)
+	rtest.Assert(t, bytes.Equal(buf, data), "data does not match")
+}
+
+func TestSaveUnpackedCompressed(t *testing.T) {
+	repository.TestAllVersions(t, testSaveUnpackedCompressed)
+}
+
+func testSaveUnpackedCompressed(t *testing.T, version uint) {
+	repo, cleanup := repository.TestRepositoryWithVersion(t, version)
+	defer cleanup()
+
+	sn := restic.Snapshot{Hostname: "foobar", Paths: []string{"/home/foo"}}
+	id, err := repo.SaveJSONUnpacked(context.TODO(), restic.SnapshotFile, &sn)
+	rtest.OK(t, err)
+
+	// check the raw plaintext, compressed files start with a version byte
+	h := restic.Handle{Type: restic.SnapshotFile, Name: id.String()}
+	var ciphertext []byte
+	err = repo.Backend().Load(context.TODO(), h, 0, 0, func(rd io.Reader) (ierr error) {
+		ciphertext, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	rtest.OK(t, err)
+
+	plaintext, err := repo.Key().Open(nil, ciphertext[:repo.Key().NonceSize()],
+		ciphertext[repo.Key().NonceSize():], nil)
+	rtest.OK(t, err)
+	if version > 1 {
+		rtest.Equals(t, byte(2), plaintext[0])
+	} else {
+		rtest.Equals(t, byte('{'), plaintext[0])
+	}
+
+	var sn2 restic.Snapshot
+	rtest.OK(t, repo.LoadJSONUnpacked(context.TODO(), restic.SnapshotFile, id, &sn2))
+	rtest.Equals(t, sn.Hostname, sn2.Hostname)
+	rtest.Equals(t, sn.Paths, sn2.Paths)
+}
+
 func TestSaveFrom(t *testing.T) {
 	repo, cleanup := repository.TestRepository(t)
 	defer cleanup()
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 
@@ -42,6 +43,14 @@ const TestChunkerPol = chunker.Pol(0x3DA3358B4DC173)
 // password. If be is nil, an in-memory backend is used. A constant polynomial
 // is used for the chunker and low-security test parameters.
 func TestRepositoryWithBackend(t testing.TB, be restic.Backend) (r restic.Repository, cleanup func()) {
+	t.Helper()
+	return testRepositoryWithBackend(t, be, 0)
+}
+
+// testRepositoryWithBackend is like TestRepositoryWithBackend, but creates a
+// repository with the given version. When version is 0,
+// restic.StableRepoVersion is used.
+func testRepositoryWithBackend(t testing.TB, be restic.Backend, version uint) (r restic.Repository, cleanup func()) {
 	t.Helper()
 	TestUseLowSecurityKDFParameters(t)
 	restic.TestDisableCheckPolynomial(t)
@@ -53,7 +62,11 @@ func TestRepositoryWithBackend(t testing.TB, be restic.Backend) (r restic.Reposi
 
 	repo := New(be)
 
-	cfg := restic.TestCreateConfig(t, TestChunkerPol)
+	if version == 0 {
+		version = restic.StableRepoVersion
+	}
+
+	cfg := restic.TestCreateConfigWithVersion(t, TestChunkerPol, version)
 	err := repo.init(context.TODO(), test.TestPassword, cfg)
 	if err != nil {
 		t.Fatalf("TestRepository(): initialize repo failed: %v", err)
@@ -71,6 +84,13 @@ func TestRepositoryWithBackend(t testing.TB, be restic.Backend) (r restic.Reposi
 // a non-existing directory, a local backend is created there and this is used
 // instead. The directory is not removed, but left there for inspection.
 func TestRepository(t testing.TB) (r restic.Repository, cleanup func()) {
+	t.Helper()
+	return TestRepositoryWithVersion(t, 0)
+}
+
+// TestRepositoryWithVersion returns a repository with the given version
+// like TestRepository.
+func TestRepositoryWithVersion(t testing.TB, version uint) (r restic.Repository, cleanup func()) {
 	t.Helper()
 	dir := os.Getenv("RESTIC_TEST_REPO")
 	if dir != "" {
@@ -80,7 +100,7 @@ func TestRepository(t testing.TB) (r restic.Repository, cleanup func()) {
 			if err != nil {
 				t.Fatalf("error creating local backend at %v: %v", dir, err)
 			}
-			return TestRepositoryWithBackend(t, be)
+			return testRepositoryWithBackend(t, be, version)
 		}
 
 		if err == nil {
@@ -88,7 +108,17 @@ func TestRepository(t testing.TB) (r restic.Repository, cleanup func()) {
 		}
 	}
 
-	return TestRepositoryWithBackend(t, nil)
+	return testRepositoryWithBackend(t, nil, version)
+}
+
+// TestAllVersions runs the test function fn for all supported repository
+// versions.
+func TestAllVersions(t *testing.T, fn func(t *testing.T, version uint)) {
+	for version := uint(restic.MinRepoVersion); version <= uint(restic.MaxRepoVersion); version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			fn(t, version)
+		})
+	}
 }
 
 // TestOpenLocal opens a local repository.
@@ -9,13 +9,28 @@ import (
 // Blob is one part of a file or a tree.
 type Blob struct {
 	BlobHandle
-	Length uint
-	Offset uint
+	Length             uint
+	Offset             uint
+	UncompressedLength uint
 }
 
 func (b Blob) String() string {
-	return fmt.Sprintf("<Blob (%v) %v, offset %v, length %v>",
-		b.Type, b.ID.Str(), b.Offset, b.Length)
+	return fmt.Sprintf("<Blob (%v) %v, offset %v, length %v, uncompressed length %v>",
+		b.Type, b.ID.Str(), b.Offset, b.Length, b.UncompressedLength)
+}
+
+// DataLength returns the length of the plaintext content of the blob.
+func (b Blob) DataLength() uint {
+	if b.UncompressedLength != 0 {
+		return b.UncompressedLength
+	}
+	return uint(PlaintextLength(int(b.Length)))
+}
+
+// IsCompressed returns true if the blob content is compressed, which is only
+// supported by repositories with version 2.
+func (b Blob) IsCompressed() bool {
+	return b.UncompressedLength != 0
 }
 
 // PackedBlob is a blob stored within a file.
@@ -18,9 +18,19 @@ type Config struct {
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
 }
 
-// RepoVersion is the version that is written to the config when a repository
-// is newly created with Init().
-const RepoVersion = 1
+const (
+	// MinRepoVersion is the oldest repository version supported.
+	MinRepoVersion = 1
+	// MaxRepoVersion is the newest repository version supported. Version 2
+	// adds compression of blobs and unpacked files.
+	MaxRepoVersion = 2
+	// StableRepoVersion is the version that is written to the config when a
+	// repository is newly created with Init() and no version is requested.
+	StableRepoVersion = 2
+	// RepoVersion is kept for compatibility, it is the same as
+	// StableRepoVersion.
+	RepoVersion = StableRepoVersion
+)
 
 // JSONUnpackedLoader loads unpacked JSON.
 type JSONUnpackedLoader interface {
@@ -28,20 +38,30 @@ type JSONUnpackedLoader interface {
 }
 
 // CreateConfig creates a config file with a randomly selected polynomial and
-// ID.
+// ID for a repository with StableRepoVersion.
 func CreateConfig() (Config, error) {
+	return CreateConfigWithVersion(StableRepoVersion)
+}
+
+// CreateConfigWithVersion creates a config file with a randomly selected
+// polynomial and ID for a repository with the given version.
+func CreateConfigWithVersion(version uint) (Config, error) {
 	var (
 		err error
 		cfg Config
 	)
 
+	if version < MinRepoVersion || version > MaxRepoVersion {
+		return Config{}, errors.Errorf("unsupported repository version %v", version)
+	}
+
 	cfg.ChunkerPolynomial, err = chunker.RandomPolynomial()
 	if err != nil {
 		return Config{}, errors.Wrap(err, "chunker.RandomPolynomial")
 	}
 
 	cfg.ID = NewRandomID().String()
-	cfg.Version = RepoVersion
+	cfg.Version = version
 
 	debug.Log("New config: %#v", cfg)
 	return cfg, nil
@@ -49,10 +69,16 @@ func CreateConfig() (Config, error) {
 
 // TestCreateConfig creates a config for use within tests.
 func TestCreateConfig(t testing.TB, pol chunker.Pol) (cfg Config) {
+	return TestCreateConfigWithVersion(t, pol, StableRepoVersion)
+}
+
+// TestCreateConfigWithVersion creates a config with the given version for use
+// within tests.
+func TestCreateConfigWithVersion(t testing.TB, pol chunker.Pol, version uint) (cfg Config) {
 	cfg.ChunkerPolynomial = pol
 
 	cfg.ID = NewRandomID().String()
-	cfg.Version = RepoVersion
+	cfg.Version = version
 
 	return cfg
 }
@@ -77,8 +103,8 @@ func LoadConfig(ctx context.Context, r JSONUnpackedLoader) (Config, error) {
 		return Config{}, err
 	}
 
-	if cfg.Version != RepoVersion {
-		return Config{}, errors.New("unsupported repository version")
+	if cfg.Version < MinRepoVersion || cfg.Version > MaxRepoVersion {
+		return Config{}, errors.Errorf("unsupported repository version %v", cfg.Version)
 	}
 
 	if checkPolynomial {
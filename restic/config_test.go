@@ -32,7 +32,7 @@ func TestConfig(t *testing.T) {
 		return restic.ID{}, nil
 	}
 
-	cfg1, err := restic.CreateConfig()
+	cfg1, err := restic.CreateConfigWithVersion(restic.MaxRepoVersion)
 	rtest.OK(t, err)
 
 	_, err = saver(save).SaveJSONUnpacked(restic.ConfigFile, cfg1)
@@ -54,3 +54,16 @@ func TestConfig(t *testing.T) {
 	rtest.Assert(t, cfg1 == cfg2,
 		"configs aren't equal: %v != %v", cfg1, cfg2)
 }
+
+func TestCreateConfigVersion(t *testing.T) {
+	for _, version := range []uint{restic.MinRepoVersion, restic.MaxRepoVersion} {
+		cfg, err := restic.CreateConfigWithVersion(version)
+		rtest.OK(t, err)
+		rtest.Equals(t, version, cfg.Version)
+	}
+
+	for _, version := range []uint{0, restic.MaxRepoVersion + 1} {
+		_, err := restic.CreateConfigWithVersion(version)
+		rtest.Assert(t, err != nil, "expected error for repository version %v", version)
+	}
+}
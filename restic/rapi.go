@@ -12,8 +12,8 @@ func (id *ID) DirectoryPrefix() string {
 	return id.String()[:2]
 }
 
-// DecryptAndCheck decrypts the blob contents, optionally checking if the content
-// is valid.
+// DecryptAndCheck decrypts and decompresses the blob contents, optionally
+// checking if the content is valid.
 func (blob *Blob) DecryptAndCheck(reader io.ReaderAt, key *crypto.Key, check bool) ([]byte, error) {
 	// load blob from pack
 
@@ -36,6 +36,13 @@ func (blob *Blob) DecryptAndCheck(reader io.ReaderAt, key *crypto.Key, check boo
 		return nil, fmt.Errorf("decrypting blob %v failed: %v", blob.ID, err)
 	}
 
+	if blob.IsCompressed() {
+		plaintext, err = crypto.Decompress(make([]byte, 0, blob.DataLength()), plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing blob %v failed: %v", blob.ID, err)
+		}
+	}
+
 	if check && !Hash(plaintext).Equal(blob.ID) {
 		return nil, fmt.Errorf("blob %v returned invalid hash", blob.ID)
 	}
@@ -0,0 +1,50 @@
+package restic
+
+import (
+	"context"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+)
+
+// ReplaceSnapshot saves the modified snapshot sn as a new snapshot file and
+// removes the file sn was loaded from. Original is set to the ID of the
+// first version of the snapshot if it was unset, so all the versions share
+// it. Afterwards sn has the new ID, which is returned.
+//
+// The caller should hold an exclusive lock on the repository.
+func ReplaceSnapshot(ctx context.Context, repo Repository, sn *Snapshot) (ID, error) {
+	oldID := sn.ID()
+	if oldID == nil {
+		return ID{}, errors.New("snapshot has no ID")
+	}
+
+	// retain the original snapshot ID over all changes
+	if sn.Original == nil {
+		sn.Original = oldID
+	}
+
+	id, err := repo.SaveJSONUnpacked(ctx, SnapshotFile, sn)
+	if err != nil {
+		return ID{}, err
+	}
+	debug.Log("new snapshot saved as %v", id)
+
+	// nothing was changed, the new file is the old one
+	if id.Equal(*oldID) {
+		return id, nil
+	}
+
+	if err = repo.Flush(ctx); err != nil {
+		return ID{}, err
+	}
+
+	h := Handle{Type: SnapshotFile, Name: oldID.String()}
+	if err = repo.Backend().Remove(ctx, h); err != nil {
+		return ID{}, err
+	}
+	debug.Log("old snapshot %v removed", oldID)
+
+	sn.id = &id
+	return id, nil
+}
@@ -0,0 +1,46 @@
+package restic_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestReplaceSnapshot(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	ctx := context.TODO()
+	sn := restic.TestCreateSnapshot(t, repo, time.Unix(1500000000, 0), 1, 0)
+	firstID := *sn.ID()
+
+	sn.AddTags([]string{"foo"})
+	id, err := restic.ReplaceSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+	rtest.Equals(t, id, *sn.ID())
+	rtest.Equals(t, firstID, *sn.Original)
+
+	sn.Hostname = "other"
+	id2, err := restic.ReplaceSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+
+	// only the last version is left, all of them share the original
+	snapshots, err := restic.FindFilteredSnapshots(ctx, repo, nil, nil, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(snapshots))
+	rtest.Equals(t, id2, *snapshots[0].ID())
+	rtest.Equals(t, firstID, *snapshots[0].Original)
+	rtest.Equals(t, []string{"test", "foo"}, snapshots[0].Tags)
+	rtest.Equals(t, "other", snapshots[0].Hostname)
+
+	// saving an unchanged snapshot does not remove it
+	_, err = restic.ReplaceSnapshot(ctx, repo, sn)
+	rtest.OK(t, err)
+	snapshots, err = restic.FindFilteredSnapshots(ctx, repo, nil, nil, nil)
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(snapshots))
+}
@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/rubiojr/rapi/internal/checker"
+	"github.com/rubiojr/rapi/checker"
 	"github.com/rubiojr/rapi/repository"
 	"github.com/rubiojr/rapi/restic"
 )
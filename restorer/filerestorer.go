@@ -35,6 +35,7 @@ type fileInfo struct {
 	size       int64
 	location   string      // file on local filesystem relative to restorer basedir
 	blobs      interface{} // blobs of the file
+	damaged    bool        // some blobs of the file could not be restored
 }
 
 type fileBlobInfo struct {
@@ -59,6 +60,11 @@ type fileRestorer struct {
 	dst   string
 	files []*fileInfo
 	Error func(string, error) error
+	// CompleteBlob is called after a blob has been written to a file.
+	CompleteBlob func(location string, bytes uint64)
+	// Damaged is called for missing or damaged blobs instead of Error if
+	// set, see Restorer.Damaged.
+	Damaged func(Damage)
 }
 
 func newFileRestorer(dst string,
@@ -108,9 +114,28 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 	// approximation to shorten restore times by up to 19% in some test.
 	var packOrder restic.IDs
 
+	addPack := func(packID restic.ID, file *fileInfo) {
+		pack, ok := packs[packID]
+		if !ok {
+			pack = &packInfo{
+				id:    packID,
+				files: make(map[*fileInfo]struct{}),
+			}
+			packs[packID] = pack
+			packOrder = append(packOrder, packID)
+		}
+		pack.files[file] = struct{}{}
+	}
+
 	// create packInfo from fileInfo
 	for _, file := range r.files {
 		fileBlobs := file.blobs.(restic.IDs)
+		if r.Damaged != nil {
+			// blobs missing from the index leave gaps in the file, the
+			// offsets of the other blobs must be stored
+			file.blobs = r.mapBlobsDamaged(file, fileBlobs, addPack)
+			continue
+		}
 		largeFile := len(fileBlobs) > largeFileBlobCount
 		var packsMap map[restic.ID][]fileBlobInfo
 		if largeFile {
@@ -120,18 +145,9 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 		err := r.forEachBlob(fileBlobs, func(packID restic.ID, blob restic.Blob) {
 			if largeFile {
 				packsMap[packID] = append(packsMap[packID], fileBlobInfo{id: blob.ID, offset: fileOffset})
-				fileOffset += int64(blob.Length) - crypto.Extension
+				fileOffset += int64(blob.DataLength())
 			}
-			pack, ok := packs[packID]
-			if !ok {
-				pack = &packInfo{
-					id:    packID,
-					files: make(map[*fileInfo]struct{}),
-				}
-				packs[packID] = pack
-				packOrder = append(packOrder, packID)
-			}
-			pack.files[file] = struct{}{}
+			addPack(packID, file)
 		})
 		if err != nil {
 			// repository index is messed up, can't do anything
@@ -172,7 +188,11 @@ func (r *fileRestorer) restoreFiles(ctx context.Context) error {
 		return nil
 	})
 
-	return wg.Wait()
+	if err := wg.Wait(); err != nil {
+		return err
+	}
+
+	return r.fillDamagedFiles()
 }
 
 const maxBufferSize = 4 * 1024 * 1024
@@ -182,9 +202,11 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 	// calculate pack byte range and blob->[]files->[]offsets mappings
 	start, end := int64(math.MaxInt64), int64(0)
 	blobs := make(map[restic.ID]struct {
-		offset int64                 // offset of the blob in the pack
-		length int                   // length of the blob
-		files  map[*fileInfo][]int64 // file -> offsets (plural!) of the blob in the file
+		offset     int64                 // offset of the blob in the pack
+		length     int                   // length of the blob
+		dataLength int                   // length of the blob content
+		compressed bool                  // blob content is compressed
+		files      map[*fileInfo][]int64 // file -> offsets (plural!) of the blob in the file
 	})
 	for file := range pack.files {
 		addBlob := func(blob restic.Blob, fileOffset int64) {
@@ -198,6 +220,8 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 			if !ok {
 				blobInfo.offset = int64(blob.Offset)
 				blobInfo.length = int(blob.Length)
+				blobInfo.dataLength = int(blob.DataLength())
+				blobInfo.compressed = blob.IsCompressed()
 				blobInfo.files = make(map[*fileInfo][]int64)
 				blobs[blob.ID] = blobInfo
 			}
@@ -209,7 +233,7 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 				if packID.Equal(pack.id) {
 					addBlob(blob, fileOffset)
 				}
-				fileOffset += int64(blob.Length) - crypto.Extension
+				fileOffset += int64(blob.DataLength())
 			})
 			if err != nil {
 				// restoreFiles should have caught this error before
@@ -243,6 +267,10 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 		return err
 	}
 
+	// blobs written or reported as damaged, the pack may be loaded again
+	// after an error
+	done := restic.NewIDSet()
+
 	h := restic.Handle{Type: restic.PackFile, Name: pack.id.String(), ContainedBlobType: restic.DataBlob}
 	err := r.packLoader(ctx, h, int(end-start), start, func(rd io.Reader) error {
 		bufferSize := int(end - start)
@@ -262,8 +290,21 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 			if err != nil {
 				return err
 			}
-			blobData, err = r.decryptBlob(blobID, buf)
+			currentBlobEnd = blob.offset + int64(blob.length)
+			if done.Has(blobID) {
+				continue
+			}
+			blobData, err = r.decryptBlob(blobID, buf, blob.compressed)
 			if err != nil {
+				done.Insert(blobID)
+				if r.Damaged != nil {
+					for file, offsets := range blob.files {
+						for _, offset := range offsets {
+							r.reportDamage(file, blobID, offset, int64(blob.dataLength), err)
+						}
+					}
+					continue
+				}
 				for file := range blob.files {
 					if errFile := sanitizeError(file, err); errFile != nil {
 						return errFile
@@ -271,7 +312,7 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 				}
 				continue
 			}
-			currentBlobEnd = blob.offset + int64(blob.length)
+			done.Insert(blobID)
 			for file, offsets := range blob.files {
 				for _, offset := range offsets {
 					writeToFile := func() error {
@@ -296,13 +337,29 @@ func (r *fileRestorer) downloadPack(ctx context.Context, pack *packInfo) error {
 					if err != nil {
 						return err
 					}
+					if r.CompleteBlob != nil {
+						r.CompleteBlob(file.location, uint64(len(blobData)))
+					}
 				}
 			}
 		}
 		return nil
 	})
 
-	if err != nil {
+	if err != nil && r.Damaged != nil && ctx.Err() == nil {
+		// the blobs not read before the error are lost
+		for _, blobID := range sortedBlobs {
+			if done.Has(blobID) {
+				continue
+			}
+			blob := blobs[blobID]
+			for file, offsets := range blob.files {
+				for _, offset := range offsets {
+					r.reportDamage(file, blobID, offset, int64(blob.dataLength), err)
+				}
+			}
+		}
+	} else if err != nil {
 		for file := range pack.files {
 			if errFile := sanitizeError(file, err); errFile != nil {
 				return errFile
@@ -333,7 +390,7 @@ func (r *fileRestorer) downloadBlob(rd io.Reader, blobID restic.ID, length int,
 	return buf, nil
 }
 
-func (r *fileRestorer) decryptBlob(blobID restic.ID, buf []byte) ([]byte, error) {
+func (r *fileRestorer) decryptBlob(blobID restic.ID, buf []byte, compressed bool) ([]byte, error) {
 	// TODO reconcile with Repository#loadBlob implementation
 
 	// decrypt
@@ -343,6 +400,13 @@ func (r *fileRestorer) decryptBlob(blobID restic.ID, buf []byte) ([]byte, error)
 		return nil, errors.Errorf("decrypting blob %v failed: %v", blobID, err)
 	}
 
+	if compressed {
+		plaintext, err = crypto.Decompress(nil, plaintext)
+		if err != nil {
+			return nil, errors.Errorf("decompressing blob %v failed: %v", blobID, err)
+		}
+	}
+
 	// check hash
 	if !restic.Hash(plaintext).Equal(blobID) {
 		return nil, errors.Errorf("blob %v returned invalid hash", blobID)
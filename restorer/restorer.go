@@ -21,6 +21,13 @@ type Restorer struct {
 
 	Error        func(location string, err error) error
 	SelectFilter func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool)
+	Progress     Progress
+
+	// Damaged makes RestoreTo tolerate missing or damaged data if set.
+	// Instead of calling Error, the ranges of the files which could not be
+	// restored are filled with zeros and reported to Damaged, which may be
+	// called concurrently.
+	Damaged func(Damage)
 }
 
 var restorerAbortOnAllErrors = func(location string, err error) error { return err }
@@ -221,6 +228,8 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	idx := restic.NewHardlinkIndex()
 	filerestorer := newFileRestorer(dst, res.repo.Backend().Load, res.repo.Key(), res.repo.Index().Lookup)
 	filerestorer.Error = res.Error
+	filerestorer.CompleteBlob = res.Progress.CompleteBlob
+	filerestorer.Damaged = res.Damaged
 
 	debug.Log("first pass for %q", dst)
 
@@ -258,6 +267,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 			}
 
 			filerestorer.addFile(location, node.Content, int64(node.Size))
+			res.Progress.addFile(location, node.Size)
 
 			return nil
 		},
@@ -274,7 +284,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	debug.Log("second pass for %q", dst)
 
 	// second tree pass: restore special files and filesystem metadata
-	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
+	_, err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, res.Progress.wrap(treeVisitor{
 		visitNode: func(node *restic.Node, target, location string) error {
 			debug.Log("second pass, visitNode: restore node %q", location)
 			if node.Type != "file" {
@@ -296,7 +306,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 			return res.restoreNodeMetadataTo(node, target, location)
 		},
 		leaveDir: res.restoreNodeMetadataTo,
-	})
+	}))
 	return err
 }
 
@@ -0,0 +1,272 @@
+package restorer
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/internal/errors"
+	"github.com/rubiojr/rapi/internal/filter"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// Progress holds the callbacks used by RestoreTo to report progress. All of
+// them are optional, CompleteBlob may be called concurrently.
+type Progress struct {
+	// AddFile is called for every regular file with content which is going
+	// to be restored, before any data is downloaded.
+	AddFile func(location string, size uint64)
+	// CompleteBlob is called after a chunk of data has been written to the
+	// file at location.
+	CompleteBlob func(location string, bytes uint64)
+	// CompleteItem is called after an item has been restored, including
+	// its metadata.
+	CompleteItem func(location string, node *restic.Node)
+}
+
+func (p Progress) addFile(location string, size uint64) {
+	if p.AddFile != nil {
+		p.AddFile(location, size)
+	}
+}
+
+// wrap returns a treeVisitor which calls CompleteItem after v restored a node
+// or left a directory successfully.
+func (p Progress) wrap(v treeVisitor) treeVisitor {
+	if p.CompleteItem == nil {
+		return v
+	}
+
+	complete := func(fn func(*restic.Node, string, string) error) func(*restic.Node, string, string) error {
+		return func(node *restic.Node, target, location string) error {
+			err := fn(node, target, location)
+			if err == nil {
+				p.CompleteItem(location, node)
+			}
+			return err
+		}
+	}
+
+	v.visitNode = complete(v.visitNode)
+	if v.leaveDir != nil {
+		v.leaveDir = complete(v.leaveDir)
+	}
+	return v
+}
+
+// SelectByPatterns returns a function suitable for Restorer.SelectFilter.
+// Items are restored when they match one of the include patterns (all of
+// them when includes is empty) and none of the exclude patterns. See the
+// filter package for the pattern syntax.
+func SelectByPatterns(includes, excludes []string) func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool) {
+	includePatterns := filter.ParsePatterns(includes)
+	excludePatterns := filter.ParsePatterns(excludes)
+
+	return func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool) {
+		selectedForRestore, childMayBeSelected = true, true
+		if len(includePatterns) > 0 {
+			matched, childMayMatch, err := filter.ListWithChild(includePatterns, item)
+			if err != nil {
+				debug.Log("error for include pattern: %v", err)
+			}
+			selectedForRestore = matched
+			childMayBeSelected = childMayMatch
+		}
+
+		if len(excludePatterns) > 0 {
+			matched, err := filter.List(excludePatterns, item)
+			if err != nil {
+				debug.Log("error for exclude pattern: %v", err)
+			}
+
+			// an excluded directory is not entered, so none of its children
+			// are restored either
+			if matched {
+				return false, false
+			}
+		}
+
+		return selectedForRestore, childMayBeSelected && node.Type == "dir"
+	}
+}
+
+// Damage is a byte range of a restored file which could not be restored,
+// because the data blob for it is missing from the repository or damaged.
+// The range was filled with zeros.
+type Damage struct {
+	Location string    `json:"file"`
+	Start    int64     `json:"start"`
+	End      int64     `json:"end"` // exclusive
+	BlobID   restic.ID `json:"blob_id"`
+	Error    string    `json:"error"`
+}
+
+func (r *fileRestorer) reportDamage(file *fileInfo, blobID restic.ID, offset, length int64, err error) {
+	file.lock.Lock()
+	file.damaged = true
+	file.lock.Unlock()
+
+	r.Damaged(Damage{
+		Location: file.location,
+		Start:    offset,
+		End:      offset + length,
+		BlobID:   blobID,
+		Error:    err.Error(),
+	})
+}
+
+// blobGap is a run of consecutive blobs of a file missing from the index.
+type blobGap struct {
+	first, last int
+}
+
+// notIndexed returns the error reported for the blobs of a gap.
+func notIndexed(ids restic.IDs) error {
+	if len(ids) == 1 {
+		return errors.Errorf("blob %v not found in the index", ids[0].Str())
+	}
+
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, id.Str())
+	}
+	return errors.Errorf("blobs %v not found in the index", strings.Join(strs, ", "))
+}
+
+// mapBlobsDamaged maps the blobs of file to the packs containing them, with
+// their offsets in the file, and calls addPack for each of them. Blobs
+// missing from the index are reported as damaged, each run of consecutive
+// missing blobs once.
+//
+// The sizes of the missing blobs are not known, they are derived from the
+// size of the file when there is a single gap or when all the gaps consist
+// of the same blob. Otherwise the blobs between the first and the last gap
+// cannot be placed, the whole range is reported once and not restored.
+func (r *fileRestorer) mapBlobsDamaged(file *fileInfo, blobIDs restic.IDs, addPack func(restic.ID, *fileInfo)) map[restic.ID][]fileBlobInfo {
+	packsMap := make(map[restic.ID][]fileBlobInfo)
+
+	blobs := make([]*restic.PackedBlob, len(blobIDs))
+	var gaps []blobGap
+	missing := restic.NewIDSet()
+	missingCount := 0
+	known := int64(0)
+	for i, id := range blobIDs {
+		packs := r.idx(restic.BlobHandle{ID: id, Type: restic.DataBlob})
+		if len(packs) > 0 {
+			blobs[i] = &packs[0]
+			known += int64(packs[0].DataLength())
+			continue
+		}
+
+		if len(gaps) > 0 && gaps[len(gaps)-1].last == i-1 {
+			gaps[len(gaps)-1].last = i
+		} else {
+			gaps = append(gaps, blobGap{first: i, last: i})
+		}
+		missing.Insert(id)
+		missingCount++
+	}
+
+	add := func(blob *restic.PackedBlob, offset int64) {
+		packsMap[blob.PackID] = append(packsMap[blob.PackID], fileBlobInfo{id: blob.ID, offset: offset})
+		addPack(blob.PackID, file)
+	}
+
+	report := func(first, last int, offset, length int64) {
+		var ids restic.IDs
+		for i := first; i <= last; i++ {
+			if blobs[i] == nil {
+				ids = append(ids, blobIDs[i])
+			}
+		}
+		r.reportDamage(file, ids[0], offset, length, notIndexed(ids))
+	}
+
+	// the size of each missing blob, if it can be derived
+	missingSize := int64(-1)
+	unknown := file.size - known
+	switch {
+	case unknown < 0:
+		// the file size does not match the blobs in the index
+	case len(gaps) == 1:
+		// only the size of the whole gap is needed
+	case len(missing) == 1 && unknown%int64(missingCount) == 0:
+		missingSize = unknown / int64(missingCount)
+	}
+
+	if len(gaps) <= 1 || missingSize >= 0 {
+		offset := int64(0)
+		for i := 0; i < len(blobIDs); i++ {
+			if blobs[i] != nil {
+				add(blobs[i], offset)
+				offset += int64(blobs[i].DataLength())
+				continue
+			}
+
+			gap := gaps[0]
+			gaps = gaps[1:]
+			length := unknown
+			if missingSize >= 0 {
+				length = missingSize * int64(gap.last-gap.first+1)
+			}
+			if length < 0 {
+				length = 0
+			}
+			report(gap.first, gap.last, offset, length)
+			offset += length
+			i = gap.last
+		}
+		return packsMap
+	}
+
+	first, last := gaps[0].first, gaps[len(gaps)-1].last
+
+	start := int64(0)
+	for i := 0; i < first; i++ {
+		add(blobs[i], start)
+		start += int64(blobs[i].DataLength())
+	}
+
+	// the blobs after the last gap end with the file
+	end := file.size
+	for i := len(blobIDs) - 1; i > last; i-- {
+		end -= int64(blobs[i].DataLength())
+		add(blobs[i], end)
+	}
+	if end < start {
+		// the file size does not match the blobs in the index
+		end = start
+	}
+
+	report(first, last, start, end-start)
+	return packsMap
+}
+
+// fillDamagedFiles sets the size of the damaged files, creating the ones of
+// which nothing could be restored. The ranges not written read as zeros.
+func (r *fileRestorer) fillDamagedFiles() error {
+	for _, file := range r.files {
+		if !file.damaged {
+			continue
+		}
+
+		flags := os.O_WRONLY | os.O_CREATE
+		if !file.inProgress {
+			flags |= os.O_TRUNC
+		}
+		f, err := os.OpenFile(r.targetPath(file.location), flags, 0600)
+		if err == nil {
+			err = f.Truncate(file.size)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			if err = r.Error(file.location, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package restorer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rubiojr/rapi/internal/errors"
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/repository"
+	"github.com/rubiojr/rapi/restic"
+)
+
+func TestRestorerProgress(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	_, id := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo":   File{Data: "content: foo\n"},
+			"empty": File{Data: ""},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+		},
+	})
+
+	res, err := NewRestorer(context.TODO(), repo, id)
+	rtest.OK(t, err)
+
+	var (
+		m         sync.Mutex
+		added     = make(map[string]uint64)
+		written   = make(map[string]uint64)
+		completed []string
+	)
+	res.Progress = Progress{
+		AddFile: func(location string, size uint64) {
+			added[location] = size
+		},
+		CompleteBlob: func(location string, bytes uint64) {
+			m.Lock()
+			written[location] += bytes
+			m.Unlock()
+		},
+		CompleteItem: func(location string, node *restic.Node) {
+			completed = append(completed, location)
+		},
+	}
+
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+	foo := filepath.FromSlash("/foo")
+	file := filepath.FromSlash("/dir/file")
+	rtest.Equals(t, map[string]uint64{foo: 13, file: 14}, added)
+	rtest.Equals(t, added, written)
+
+	sort.Strings(completed)
+	rtest.Equals(t, []string{filepath.FromSlash("/dir"), file, filepath.FromSlash("/empty"), foo}, completed)
+}
+
+func TestSelectByPatterns(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	_, id := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo.txt": File{Data: "foo"},
+			"foo.tmp": File{Data: "tmp"},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"bar.txt": File{Data: "bar"},
+					"bar.tmp": File{Data: "tmp"},
+				},
+			},
+			"other": Dir{
+				Nodes: map[string]Node{
+					"baz.txt": File{Data: "baz"},
+				},
+			},
+		},
+	})
+
+	var tests = []struct {
+		includes, excludes []string
+		files              []string
+	}{
+		{
+			files: []string{"dir/bar.tmp", "dir/bar.txt", "foo.tmp", "foo.txt", "other/baz.txt"},
+		},
+		{
+			excludes: []string{"*.tmp"},
+			files:    []string{"dir/bar.txt", "foo.txt", "other/baz.txt"},
+		},
+		{
+			excludes: []string{"/other"},
+			files:    []string{"dir/bar.tmp", "dir/bar.txt", "foo.tmp", "foo.txt"},
+		},
+		{
+			includes: []string{"/dir"},
+			files:    []string{"dir/bar.tmp", "dir/bar.txt"},
+		},
+		{
+			includes: []string{"*.txt"},
+			excludes: []string{"/other"},
+			files:    []string{"dir/bar.txt", "foo.txt"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			res, err := NewRestorer(context.TODO(), repo, id)
+			rtest.OK(t, err)
+			res.SelectFilter = SelectByPatterns(test.includes, test.excludes)
+
+			tempdir, cleanup := rtest.TempDir(t)
+			defer cleanup()
+
+			rtest.OK(t, res.RestoreTo(context.TODO(), tempdir))
+
+			var files []string
+			err = filepath.Walk(tempdir, func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if fi.Mode().IsRegular() {
+					rel, err := filepath.Rel(tempdir, path)
+					if err != nil {
+						return err
+					}
+					files = append(files, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			rtest.OK(t, err)
+
+			sort.Strings(files)
+			rtest.Equals(t, test.files, files)
+		})
+	}
+}
+
+// restoreDamaged restores the single file in content with the data blobs
+// modified by damage and returns the restored content and the damage
+// reported.
+func restoreDamaged(t *testing.T, content TestFile, damage func(repo *TestRepo)) ([]byte, []Damage) {
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	repo := newTestRepo([]TestFile{content})
+	damage(repo)
+
+	var (
+		m       sync.Mutex
+		damaged []Damage
+	)
+	r := newFileRestorer(tempdir, repo.loader, repo.key, repo.Lookup)
+	r.files = repo.files
+	r.files[0].size = int64(len(repo.fileContent(r.files[0])))
+	r.Error = func(location string, err error) error {
+		t.Errorf("unexpected error for %v: %v", location, err)
+		return nil
+	}
+	r.Damaged = func(d Damage) {
+		m.Lock()
+		damaged = append(damaged, d)
+		m.Unlock()
+	}
+
+	rtest.OK(t, r.restoreFiles(context.TODO()))
+
+	data, err := ioutil.ReadFile(r.targetPath(content.name))
+	rtest.OK(t, err)
+
+	sort.Slice(damaged, func(i, j int) bool {
+		return damaged[i].Start < damaged[j].Start
+	})
+	for i := range damaged {
+		damaged[i].Error = ""
+	}
+
+	return data, damaged
+}
+
+var damagedFile = TestFile{
+	name: "file",
+	blobs: []TestBlob{
+		{"data-1", "pack1"},
+		{"data-22", "pack2"},
+		{"data-333", "pack1"},
+		{"data-4444", "pack1"},
+	},
+}
+
+func blobID(data string) restic.ID {
+	return restic.Hash([]byte(data))
+}
+
+func TestFileRestorerDamagedBlob(t *testing.T) {
+	data, damaged := restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		blob := repo.blobs[blobID("data-333")][0]
+		repo.packsIDToData[blob.PackID][blob.Offset+blob.Length-1] ^= 0xff
+	})
+
+	rtest.Equals(t, "data-1data-22\x00\x00\x00\x00\x00\x00\x00\x00data-4444", string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 13, End: 21, BlobID: blobID("data-333")},
+	}, damaged)
+}
+
+func TestFileRestorerMissingPack(t *testing.T) {
+	data, damaged := restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		missing := repo.packsNameToID["pack1"]
+		loader := repo.loader
+		repo.loader = func(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+			if h.Name == missing.String() {
+				return errors.New("pack not found")
+			}
+			return loader(ctx, h, length, offset, fn)
+		}
+	})
+
+	rtest.Equals(t, "\x00\x00\x00\x00\x00\x00data-22"+strings.Repeat("\x00", 17), string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 0, End: 6, BlobID: blobID("data-1")},
+		{Location: "file", Start: 13, End: 21, BlobID: blobID("data-333")},
+		{Location: "file", Start: 21, End: 30, BlobID: blobID("data-4444")},
+	}, damaged)
+}
+
+func TestFileRestorerBlobNotIndexed(t *testing.T) {
+	data, damaged := restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		delete(repo.blobs, blobID("data-22"))
+	})
+
+	rtest.Equals(t, "data-1\x00\x00\x00\x00\x00\x00\x00data-333data-4444", string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 6, End: 13, BlobID: blobID("data-22")},
+	}, damaged)
+
+	// consecutive missing blobs are reported once
+	data, damaged = restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		delete(repo.blobs, blobID("data-22"))
+		delete(repo.blobs, blobID("data-333"))
+	})
+
+	rtest.Equals(t, "data-1"+strings.Repeat("\x00", 15)+"data-4444", string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 6, End: 21, BlobID: blobID("data-22")},
+	}, damaged)
+
+	// the position of the blob between two missing blobs is not known
+	data, damaged = restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		delete(repo.blobs, blobID("data-1"))
+		delete(repo.blobs, blobID("data-333"))
+	})
+
+	rtest.Equals(t, strings.Repeat("\x00", 21)+"data-4444", string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 0, End: 21, BlobID: blobID("data-1")},
+	}, damaged)
+
+	// nothing can be restored, the file is created anyway
+	data, damaged = restoreDamaged(t, damagedFile, func(repo *TestRepo) {
+		repo.blobs = make(map[restic.ID][]restic.PackedBlob)
+	})
+
+	rtest.Equals(t, strings.Repeat("\x00", 30), string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 0, End: 30, BlobID: blobID("data-1")},
+	}, damaged)
+}
+
+func TestFileRestorerRepeatedBlobNotIndexed(t *testing.T) {
+	file := TestFile{
+		name: "file",
+		blobs: []TestBlob{
+			{"data-1", "pack1"},
+			{"data-22", "pack2"},
+			{"data-1", "pack1"},
+			{"data-4444", "pack1"},
+		},
+	}
+
+	// the size of the missing blob follows from the size of the file
+	data, damaged := restoreDamaged(t, file, func(repo *TestRepo) {
+		delete(repo.blobs, blobID("data-1"))
+	})
+
+	rtest.Equals(t, "\x00\x00\x00\x00\x00\x00data-22\x00\x00\x00\x00\x00\x00data-4444", string(data))
+	rtest.Equals(t, []Damage{
+		{Location: "file", Start: 0, End: 6, BlobID: blobID("data-1")},
+		{Location: "file", Start: 13, End: 19, BlobID: blobID("data-1")},
+	}, damaged)
+}
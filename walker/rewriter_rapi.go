@@ -0,0 +1,138 @@
+package walker
+
+import (
+	"context"
+	"path"
+
+	"github.com/rubiojr/rapi/internal/debug"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// TreeLoadSaver loads and saves trees.
+type TreeLoadSaver interface {
+	TreeLoader
+	SaveTree(context.Context, *restic.Tree) (restic.ID, error)
+}
+
+// NodeRewriteFunc returns the node which replaces node at path in the
+// rewritten tree, or nil to remove it. It must not modify node, but return a
+// modified copy instead.
+type NodeRewriteFunc func(node *restic.Node, path string) *restic.Node
+
+// FailedTreeFunc is called when the subtree id of the directory at path
+// cannot be loaded. If it returns nil, the directory is removed, otherwise
+// the rewrite is aborted with the error returned.
+type FailedTreeFunc func(id restic.ID, path string, err error) error
+
+// TreeRewriter rewrites trees bottom-up. Only the trees containing modified
+// nodes are saved again, unchanged trees keep their ID.
+type TreeRewriter struct {
+	// RewriteNode is called for every node before its subtree is
+	// rewritten. All the nodes are kept if it is nil.
+	RewriteNode NodeRewriteFunc
+	// FailedTree decides what to do with the subtrees which cannot be
+	// loaded. The error is returned if it is nil.
+	FailedTree FailedTreeFunc
+
+	// rewritten caches the new IDs of the trees already rewritten, by
+	// path as RewriteNode may depend on it
+	rewritten map[rewrittenTree]restic.ID
+}
+
+type rewrittenTree struct {
+	path string
+	id   restic.ID
+}
+
+// NewTreeRewriter returns a TreeRewriter which calls rewriteNode for every
+// node, see TreeRewriter.RewriteNode.
+func NewTreeRewriter(rewriteNode NodeRewriteFunc) *TreeRewriter {
+	return &TreeRewriter{
+		RewriteNode: rewriteNode,
+		rewritten:   make(map[rewrittenTree]restic.ID),
+	}
+}
+
+// RewriteTree rewrites the tree id, found at nodepath, and all its subtrees.
+// It returns the ID of the new tree, which is id if nothing was changed.
+// The trees are saved to repo, which must be flushed afterwards.
+func (t *TreeRewriter) RewriteTree(ctx context.Context, repo TreeLoadSaver, nodepath string, id restic.ID) (restic.ID, error) {
+	if newID, ok := t.rewritten[rewrittenTree{nodepath, id}]; ok {
+		return newID, nil
+	}
+
+	tree, err := repo.LoadTree(ctx, id)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	return t.rewriteTree(ctx, repo, nodepath, id, tree)
+}
+
+func (t *TreeRewriter) rewriteTree(ctx context.Context, repo TreeLoadSaver, nodepath string, id restic.ID, tree *restic.Tree) (restic.ID, error) {
+	newTree := restic.NewTree(len(tree.Nodes))
+	changed := false
+
+	for _, node := range tree.Nodes {
+		if ctx.Err() != nil {
+			return restic.ID{}, ctx.Err()
+		}
+
+		p := path.Join(nodepath, node.Name)
+		newNode := node
+		if t.RewriteNode != nil {
+			newNode = t.RewriteNode(node, p)
+		}
+		if newNode != node {
+			changed = true
+		}
+		if newNode == nil {
+			continue
+		}
+
+		if newNode.Type == "dir" && newNode.Subtree != nil {
+			subtreeID := *newNode.Subtree
+			newID, ok := t.rewritten[rewrittenTree{p, subtreeID}]
+			if !ok {
+				subtree, err := repo.LoadTree(ctx, subtreeID)
+				if err != nil {
+					if t.FailedTree == nil {
+						return restic.ID{}, err
+					}
+					if err = t.FailedTree(subtreeID, p, err); err != nil {
+						return restic.ID{}, err
+					}
+					changed = true
+					continue
+				}
+
+				newID, err = t.rewriteTree(ctx, repo, p, subtreeID, subtree)
+				if err != nil {
+					return restic.ID{}, err
+				}
+			}
+
+			if !newID.Equal(subtreeID) {
+				n := *newNode
+				n.Subtree = &newID
+				newNode = &n
+				changed = true
+			}
+		}
+
+		newTree.Nodes = append(newTree.Nodes, newNode)
+	}
+
+	newID := id
+	if changed {
+		var err error
+		newID, err = repo.SaveTree(ctx, newTree)
+		if err != nil {
+			return restic.ID{}, err
+		}
+		debug.Log("tree %v at %v rewritten as %v", id.Str(), nodepath, newID.Str())
+	}
+
+	t.rewritten[rewrittenTree{nodepath, id}] = newID
+	return newID, nil
+}
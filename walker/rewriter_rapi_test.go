@@ -0,0 +1,121 @@
+package walker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	rtest "github.com/rubiojr/rapi/internal/test"
+	"github.com/rubiojr/rapi/restic"
+)
+
+// SavingTreeMap is a TreeMap which also stores the trees saved.
+type SavingTreeMap struct {
+	TreeMap
+	saved int
+}
+
+func (t *SavingTreeMap) SaveTree(ctx context.Context, tree *restic.Tree) (restic.ID, error) {
+	buf, err := json.Marshal(tree)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	id := restic.Hash(buf)
+	t.TreeMap[id] = tree
+	t.saved++
+
+	return id, nil
+}
+
+// listTree returns the paths of all the nodes below id.
+func listTree(t testing.TB, repo TreeLoader, id restic.ID) []string {
+	var paths []string
+	err := Walk(context.TODO(), repo, id, restic.NewIDSet(), func(_ restic.ID, path string, node *restic.Node, err error) (bool, error) {
+		if node != nil {
+			paths = append(paths, path)
+		}
+		return false, err
+	})
+	rtest.OK(t, err)
+	return paths
+}
+
+func TestTreeRewriter(t *testing.T) {
+	m, root := BuildTreeMap(TestTree{
+		"foo": TestFile{},
+		"subdir": TestTree{
+			"secret":  TestFile{},
+			"subfile": TestFile{},
+		},
+		"other": TestTree{
+			"secret": TestFile{},
+		},
+	})
+	repo := &SavingTreeMap{TreeMap: m}
+
+	var removed []string
+	rw := NewTreeRewriter(func(node *restic.Node, path string) *restic.Node {
+		if path == "/subdir/secret" {
+			removed = append(removed, path)
+			return nil
+		}
+		return node
+	})
+
+	newRoot, err := rw.RewriteTree(context.TODO(), repo, "/", root)
+	rtest.OK(t, err)
+	rtest.Assert(t, !newRoot.Equal(root), "the root tree was not rewritten")
+	rtest.Equals(t, []string{"/subdir/secret"}, removed)
+	rtest.Equals(t, 2, repo.saved)
+	rtest.Equals(t, []string{"/foo", "/other", "/other/secret", "/subdir", "/subdir/subfile"}, listTree(t, repo, newRoot))
+
+	// the unchanged subtree is reused
+	oldTree, err := repo.LoadTree(context.TODO(), root)
+	rtest.OK(t, err)
+	newTree, err := repo.LoadTree(context.TODO(), newRoot)
+	rtest.OK(t, err)
+	rtest.Equals(t, *oldTree.Find("other").Subtree, *newTree.Find("other").Subtree)
+
+	// nothing is left to remove
+	removed = nil
+	again, err := NewTreeRewriter(rw.RewriteNode).RewriteTree(context.TODO(), repo, "/", newRoot)
+	rtest.OK(t, err)
+	rtest.Equals(t, newRoot, again)
+	rtest.Equals(t, 2, repo.saved)
+	rtest.Equals(t, 0, len(removed))
+}
+
+func TestTreeRewriterFailedTree(t *testing.T) {
+	m, root := BuildTreeMap(TestTree{
+		"foo": TestFile{},
+		"subdir": TestTree{
+			"subfile": TestFile{},
+		},
+	})
+	tree := m[root]
+	delete(m, *tree.Find("subdir").Subtree)
+	repo := &SavingTreeMap{TreeMap: m}
+
+	_, err := NewTreeRewriter(nil).RewriteTree(context.TODO(), repo, "/", root)
+	rtest.Assert(t, err != nil, "missing subtree did not return an error")
+
+	var failed []string
+	rw := NewTreeRewriter(nil)
+	rw.FailedTree = func(id restic.ID, path string, err error) error {
+		failed = append(failed, path)
+		return nil
+	}
+	newRoot, err := rw.RewriteTree(context.TODO(), repo, "/", root)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"/subdir"}, failed)
+	rtest.Equals(t, []string{"/foo"}, listTree(t, repo, newRoot))
+
+	rw.FailedTree = func(id restic.ID, path string, err error) error {
+		return errors.New("abort")
+	}
+	rw.rewritten = make(map[rewrittenTree]restic.ID)
+	_, err = rw.RewriteTree(context.TODO(), repo, "/", root)
+	rtest.Assert(t, err != nil && err.Error() == "abort", "unexpected error %v", err)
+}